@@ -0,0 +1,48 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	log "github.com/ironzhang/tlog"
+	"github.com/ironzhang/zerone/pkg/registry"
+	"github.com/ironzhang/zerone/zserver"
+)
+
+type Options struct {
+	net  string
+	addr string
+}
+
+func (o *Options) Parse() {
+	flag.StringVar(&o.net, "net", "tcp", "network")
+	flag.StringVar(&o.addr, "addr", ":2380", "address")
+	flag.Parse()
+}
+
+func main() {
+	var opts Options
+	opts.Parse()
+
+	r := registry.New()
+	defer r.Close()
+
+	s := zserver.New("zerone-registry", "zerone-registry", nil)
+	defer s.Close()
+	if err := s.Register(r); err != nil {
+		log.Fatalf("register: %v", err)
+	}
+
+	go func() {
+		log.Infof("listen and serve on %s://%s", opts.net, opts.addr)
+		if err := s.ListenAndServe(opts.net, opts.addr, ""); err != nil {
+			log.Fatalf("listen and serve: %v", err)
+		}
+	}()
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+	<-ch
+}
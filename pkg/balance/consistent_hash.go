@@ -0,0 +1,152 @@
+package balance
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
+)
+
+// DefaultReplicas 一致性哈希环上每个endpoint默认的虚拟节点数
+const DefaultReplicas = 100
+
+type point struct {
+	hash uint32
+	name string
+}
+
+var _ LoadBalancer = &ConsistentHashBalancer{}
+
+// ConsistentHashBalancer 一致性哈希负载均衡器, 以endpoint的Name构建哈希环.
+//
+// 路由表实现了route.Watchable时根据变更事件增量更新哈希环,
+// 否则在每次GetEndpoint时比较endpoint列表, 只增删变化的endpoint的虚拟节点.
+type ConsistentHashBalancer struct {
+	table    route.Table
+	hash     Hash
+	replicas int
+	cancel   func()
+
+	mu        sync.RWMutex
+	points    []point
+	endpoints map[string]endpoint.Endpoint
+}
+
+func NewConsistentHashBalancer(table route.Table, hash Hash, replicas int) *ConsistentHashBalancer {
+	if hash == nil {
+		hash = crc32.ChecksumIEEE
+	}
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+	b := &ConsistentHashBalancer{
+		table:     table,
+		hash:      hash,
+		replicas:  replicas,
+		endpoints: make(map[string]endpoint.Endpoint),
+	}
+	if w, ok := table.(route.Watchable); ok {
+		b.cancel = w.Watch(b.apply)
+	}
+	b.apply(route.Diff(nil, table.ListEndpoints()))
+	return b
+}
+
+// Close 取消对路由表变更的订阅
+func (b *ConsistentHashBalancer) Close() error {
+	if b.cancel != nil {
+		b.cancel()
+	}
+	return nil
+}
+
+func (b *ConsistentHashBalancer) Name() string {
+	return ConsistentHashBalancerName
+}
+
+func (b *ConsistentHashBalancer) GetEndpoint(key []byte) (endpoint.Endpoint, error) {
+	if b.cancel == nil {
+		b.sync()
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if len(b.points) <= 0 {
+		return endpoint.Endpoint{}, ErrNoEndpoint
+	}
+	h := b.hash(key)
+	i := sort.Search(len(b.points), func(i int) bool {
+		return b.points[i].hash >= h
+	})
+	if i >= len(b.points) {
+		i = 0
+	}
+	return b.endpoints[b.points[i].name], nil
+}
+
+// sync 路由表不支持变更通知时, 比较endpoint列表并增量更新哈希环
+func (b *ConsistentHashBalancer) sync() {
+	eps := b.table.ListEndpoints()
+
+	b.mu.RLock()
+	changed := len(eps) != len(b.endpoints)
+	for i := 0; !changed && i < len(eps); i++ {
+		old, ok := b.endpoints[eps[i].Name]
+		changed = !ok || !old.Equal(&eps[i])
+	}
+	var olds []endpoint.Endpoint
+	if changed {
+		olds = make([]endpoint.Endpoint, 0, len(b.endpoints))
+		for _, ep := range b.endpoints {
+			olds = append(olds, ep)
+		}
+	}
+	b.mu.RUnlock()
+
+	if changed {
+		b.apply(route.Diff(olds, eps))
+	}
+}
+
+func (b *ConsistentHashBalancer) apply(e route.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(e.Removed) > 0 {
+		removed := make(map[string]bool, len(e.Removed))
+		for _, ep := range e.Removed {
+			removed[ep.Name] = true
+			delete(b.endpoints, ep.Name)
+		}
+		points := b.points[:0]
+		for _, p := range b.points {
+			if !removed[p.name] {
+				points = append(points, p)
+			}
+		}
+		b.points = points
+	}
+	for _, ep := range e.Updated {
+		b.endpoints[ep.Name] = ep
+	}
+	if len(e.Added) > 0 {
+		for _, ep := range e.Added {
+			if _, ok := b.endpoints[ep.Name]; ok {
+				continue
+			}
+			b.endpoints[ep.Name] = ep
+			for i := 0; i < b.replicas; i++ {
+				b.points = append(b.points, point{hash: b.hash([]byte(ep.Name + "#" + strconv.Itoa(i))), name: ep.Name})
+			}
+		}
+		sort.Slice(b.points, func(i, j int) bool {
+			if b.points[i].hash != b.points[j].hash {
+				return b.points[i].hash < b.points[j].hash
+			}
+			return b.points[i].name < b.points[j].name
+		})
+	}
+}
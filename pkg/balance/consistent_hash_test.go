@@ -0,0 +1,105 @@
+package balance
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
+)
+
+type TestWatchableTB struct {
+	route.Notifier
+	endpoints []endpoint.Endpoint
+}
+
+func (t *TestWatchableTB) ListEndpoints() []endpoint.Endpoint {
+	return t.endpoints
+}
+
+func (t *TestWatchableTB) setEndpoints(eps []endpoint.Endpoint) {
+	e := route.Diff(t.endpoints, eps)
+	t.endpoints = eps
+	t.Notify(e)
+}
+
+type TestStaticTB struct {
+	endpoints []endpoint.Endpoint
+}
+
+func (t *TestStaticTB) ListEndpoints() []endpoint.Endpoint {
+	return t.endpoints
+}
+
+func TestConsistentHashBalancer(t *testing.T) {
+	b := NewConsistentHashBalancer(TestTB{}, nil, 0)
+	RunLoadBalancerTests(t, b, "ConsistentHashBalancer", 10)
+
+	b = NewConsistentHashBalancer(&TestStaticTB{}, nil, 0)
+	if _, err := b.GetEndpoint([]byte("key")); err != ErrNoEndpoint {
+		t.Errorf("GetEndpoint: got %v, want %v", err, ErrNoEndpoint)
+	}
+}
+
+func RunConsistentHashTests(t *testing.T, name string, tb route.Table, set func([]endpoint.Endpoint)) {
+	eps := []endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:2000"},
+		{Name: "1", Net: "tcp", Addr: "localhost:2001"},
+		{Name: "2", Net: "tcp", Addr: "localhost:2002"},
+	}
+	set(eps)
+	b := NewConsistentHashBalancer(tb, nil, 0)
+	defer b.Close()
+
+	const n = 1000
+	olds := make([]string, n)
+	for i := 0; i < n; i++ {
+		ep, err := b.GetEndpoint([]byte(strconv.Itoa(i)))
+		if err != nil {
+			t.Fatalf("%s: GetEndpoint: %v", name, err)
+		}
+		olds[i] = ep.Name
+	}
+
+	// 删除一个endpoint, 只有原本映射到该endpoint的key会迁移
+	set(eps[:2])
+	for i := 0; i < n; i++ {
+		ep, err := b.GetEndpoint([]byte(strconv.Itoa(i)))
+		if err != nil {
+			t.Fatalf("%s: GetEndpoint: %v", name, err)
+		}
+		if ep.Name == "2" {
+			t.Fatalf("%s: key %d is mapped to removed endpoint", name, i)
+		}
+		if olds[i] != "2" && ep.Name != olds[i] {
+			t.Errorf("%s: key %d moved from %s to %s", name, i, olds[i], ep.Name)
+		}
+	}
+
+	// 更新endpoint地址不影响key的映射
+	set([]endpoint.Endpoint{eps[0], {Name: "1", Net: "tcp", Addr: "localhost:3001"}})
+	for i := 0; i < n; i++ {
+		ep, _ := b.GetEndpoint([]byte(strconv.Itoa(i)))
+		if ep.Name == "1" && ep.Addr != "localhost:3001" {
+			t.Fatalf("%s: key %d: addr got %s, want localhost:3001", name, i, ep.Addr)
+		}
+	}
+}
+
+func TestConsistentHashBalancerWatch(t *testing.T) {
+	tb := &TestWatchableTB{}
+	RunConsistentHashTests(t, "Watchable", tb, tb.setEndpoints)
+}
+
+func TestConsistentHashBalancerSync(t *testing.T) {
+	tb := &TestStaticTB{}
+	RunConsistentHashTests(t, "Static", tb, func(eps []endpoint.Endpoint) { tb.endpoints = eps })
+}
+
+func BenchmarkConsistentHashBalancer(b *testing.B) {
+	lb := NewConsistentHashBalancer(TestTB{}, nil, 0)
+	key := []byte("key")
+	for i := 0; i < b.N; i++ {
+		lb.GetEndpoint(key)
+	}
+}
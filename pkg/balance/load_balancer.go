@@ -14,6 +14,8 @@ const (
 	RoundRobinBalancerName = "RoundRobinBalancer"
 	HashBalancerName       = "HashBalancer"
 	NodeBalancerName       = "NodeBalancer"
+
+	ConsistentHashBalancerName = "ConsistentHashBalancer"
 )
 
 var (
@@ -12,9 +12,9 @@ type TestTB struct {
 
 func (TestTB) ListEndpoints() []endpoint.Endpoint {
 	return []endpoint.Endpoint{
-		{"0", "tcp", "localhost:2000", 0.0},
-		{"1", "tcp", "localhost:2001", 0.1},
-		{"2", "tcp", "localhost:2002", 0.2},
+		{Name: "0", Net: "tcp", Addr: "localhost:2000", Load: 0.0},
+		{Name: "1", Net: "tcp", Addr: "localhost:2001", Load: 0.1},
+		{Name: "2", Net: "tcp", Addr: "localhost:2002", Load: 0.2},
 	}
 }
 
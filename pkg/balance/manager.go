@@ -2,6 +2,7 @@ package balance
 
 import (
 	"fmt"
+	"io"
 
 	"github.com/ironzhang/zerone/pkg/route"
 )
@@ -21,6 +22,7 @@ func (p *Manager) Init(table route.Table, hash Hash) *Manager {
 	p.m[RoundRobinBalancerName] = NewRoundRobinBalancer(table)
 	p.m[HashBalancerName] = NewHashBalancer(table, hash)
 	p.m[NodeBalancerName] = NewNodeBalancer(table)
+	p.m[ConsistentHashBalancerName] = NewConsistentHashBalancer(table, hash, 0)
 	p.d = p.m[RandomBalancerName]
 	return p
 }
@@ -39,3 +41,13 @@ func (p *Manager) SetDefaultLoadBalancer(name string) error {
 	}
 	return fmt.Errorf("unknown %q load balancer name", name)
 }
+
+// Close 关闭实现了io.Closer的负载均衡器, 如取消一致性哈希负载均衡器对路由表的订阅
+func (p *Manager) Close() error {
+	for _, lb := range p.m {
+		if c, ok := lb.(io.Closer); ok {
+			c.Close()
+		}
+	}
+	return nil
+}
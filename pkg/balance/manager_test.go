@@ -1,11 +1,15 @@
 package balance
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+)
 
 func TestManager(t *testing.T) {
 	m := NewManager(TestTB{}, nil)
 
-	names := []string{RandomBalancerName, RoundRobinBalancerName, HashBalancerName, NodeBalancerName}
+	names := []string{RandomBalancerName, RoundRobinBalancerName, HashBalancerName, NodeBalancerName, ConsistentHashBalancerName}
 	for i, name := range names {
 		lb := m.GetLoadBalancer(name)
 		if got, want := lb.Name(), name; got != want {
@@ -21,3 +25,20 @@ func TestManager(t *testing.T) {
 		t.Logf("default: got %v", got)
 	}
 }
+
+func TestManagerClose(t *testing.T) {
+	tb := &TestWatchableTB{}
+	tb.setEndpoints([]endpoint.Endpoint{{Name: "0", Net: "tcp", Addr: "localhost:2000"}})
+	m := NewManager(tb, nil)
+	m.Close()
+
+	// 关闭后一致性哈希负载均衡器不再订阅路由表变更
+	tb.setEndpoints([]endpoint.Endpoint{{Name: "1", Net: "tcp", Addr: "localhost:2001"}})
+	ep, err := m.GetLoadBalancer(ConsistentHashBalancerName).GetEndpoint([]byte("key"))
+	if err != nil {
+		t.Fatalf("get endpoint: %v", err)
+	}
+	if got, want := ep.Name, "0"; got != want {
+		t.Errorf("endpoint: got %v, want %v", got, want)
+	}
+}
@@ -9,6 +9,8 @@ type Endpoint struct {
 	Net  string
 	Addr string
 	Load float64
+	Tags Tags `json:",omitempty"` // 标签, 如: version=v2, 由NewTags创建
+	TLS  bool `json:",omitempty"` // 是否使用TLS连接
 }
 
 func (p *Endpoint) Node() string {
@@ -23,3 +25,17 @@ func (p *Endpoint) String() string {
 func (p *Endpoint) Equal(a interface{}) bool {
 	return *p == *a.(*Endpoint)
 }
+
+// Match 判断endpoint是否包含tags中的所有标签
+func (p *Endpoint) Match(tags map[string]string) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	m := p.Tags.Map()
+	for k, v := range tags {
+		if tv, ok := m[k]; !ok || tv != v {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,9 @@
 package endpoint
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+)
 
 func TestEndpointEqual(t *testing.T) {
 	tests := []struct {
@@ -18,6 +21,16 @@ func TestEndpointEqual(t *testing.T) {
 			b:    &Endpoint{Name: "n1", Net: "tcp", Addr: "localhost:2000", Load: 0.1},
 			want: false,
 		},
+		{
+			a:    Endpoint{Name: "n1", Net: "tcp", Addr: "localhost:2000", Tags: NewTags(map[string]string{"version": "v2"})},
+			b:    &Endpoint{Name: "n1", Net: "tcp", Addr: "localhost:2000", Tags: NewTags(map[string]string{"version": "v2"})},
+			want: true,
+		},
+		{
+			a:    Endpoint{Name: "n1", Net: "tcp", Addr: "localhost:2000", Tags: NewTags(map[string]string{"version": "v1"})},
+			b:    &Endpoint{Name: "n1", Net: "tcp", Addr: "localhost:2000", Tags: NewTags(map[string]string{"version": "v2"})},
+			want: false,
+		},
 	}
 	for i, tt := range tests {
 		if got, want := tt.a.Equal(tt.b), tt.want; got != want {
@@ -27,3 +40,59 @@ func TestEndpointEqual(t *testing.T) {
 		}
 	}
 }
+
+func TestEndpointMatch(t *testing.T) {
+	ep := Endpoint{Name: "n1", Tags: NewTags(map[string]string{"version": "v2", "zone": "a"})}
+	tests := []struct {
+		tags map[string]string
+		want bool
+	}{
+		{tags: nil, want: true},
+		{tags: map[string]string{"version": "v2"}, want: true},
+		{tags: map[string]string{"version": "v2", "zone": "a"}, want: true},
+		{tags: map[string]string{"version": "v1"}, want: false},
+		{tags: map[string]string{"version": "v2", "zone": "b"}, want: false},
+		{tags: map[string]string{"idc": "bj"}, want: false},
+	}
+	for i, tt := range tests {
+		if got, want := ep.Match(tt.tags), tt.want; got != want {
+			t.Errorf("%d: got %v, want %v", i, got, want)
+		} else {
+			t.Logf("%d: got %v", i, got)
+		}
+	}
+}
+
+func TestTags(t *testing.T) {
+	a := NewTags(map[string]string{"version": "v2", "zone": "a"})
+	b := NewTags(map[string]string{"zone": "a", "version": "v2"})
+	if a != b {
+		t.Errorf("tags are not canonical: %q != %q", a, b)
+	}
+	if v, ok := a.Get("zone"); !ok || v != "a" {
+		t.Errorf("get zone: got %q, %v", v, ok)
+	}
+	if got := NewTags(nil); got != "" {
+		t.Errorf("empty tags: got %q", got)
+	}
+
+	// 序列化为JSON对象, 反序列化后保持规范形式
+	data, err := json.Marshal(Endpoint{Name: "n1", Tags: a})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if got, want := string(data), `{"Name":"n1","Net":"","Addr":"","Load":0,"Tags":{"version":"v2","zone":"a"}}`; got != want {
+		t.Errorf("marshal: got %s, want %s", got, want)
+	}
+	var ep Endpoint
+	if err = json.Unmarshal([]byte(`{"Name":"n1","Tags":{"zone":"a","version":"v2"}}`), &ep); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if ep.Tags != a {
+		t.Errorf("unmarshal: got %q, want %q", ep.Tags, a)
+	}
+	data, _ = json.Marshal(Endpoint{Name: "n1"})
+	if got, want := string(data), `{"Name":"n1","Net":"","Addr":"","Load":0}`; got != want {
+		t.Errorf("marshal without tags: got %s, want %s", got, want)
+	}
+}
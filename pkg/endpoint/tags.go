@@ -0,0 +1,50 @@
+package endpoint
+
+import (
+	"encoding/json"
+)
+
+// Tags endpoint的标签, 以键排序后的JSON对象保存, 使Endpoint保持可比较.
+// 序列化为JSON对象, 如: {"version":"v2"}
+type Tags string
+
+// NewTags 由map创建标签, m为空时返回空标签
+func NewTags(m map[string]string) Tags {
+	if len(m) == 0 {
+		return ""
+	}
+	data, _ := json.Marshal(m)
+	return Tags(data)
+}
+
+// Map 返回标签的map形式, 修改返回值不影响标签
+func (t Tags) Map() map[string]string {
+	if t == "" {
+		return nil
+	}
+	var m map[string]string
+	json.Unmarshal([]byte(t), &m)
+	return m
+}
+
+// Get 返回key对应的标签值
+func (t Tags) Get(key string) (string, bool) {
+	v, ok := t.Map()[key]
+	return v, ok
+}
+
+func (t Tags) MarshalJSON() ([]byte, error) {
+	if t == "" {
+		return []byte("null"), nil
+	}
+	return []byte(t), nil
+}
+
+func (t *Tags) UnmarshalJSON(data []byte) error {
+	var m map[string]string
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*t = NewTags(m)
+	return nil
+}
@@ -0,0 +1,246 @@
+// Package memory 进程内的govern.Driver实现, 用于测试和单进程部署, 支持租约过期和故障注入
+package memory
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ironzhang/x-pearls/govern"
+)
+
+// DriverName 内存driver的名称
+const DriverName = "memory"
+
+// Config 内存driver配置
+type Config struct {
+	ExpireInterval time.Duration // 租约过期检查间隔, 默认为100ms
+}
+
+func (c *Config) setDefaults() {
+	if c.ExpireInterval <= 0 {
+		c.ExpireInterval = 100 * time.Millisecond
+	}
+}
+
+var (
+	mu     sync.Mutex
+	stores = make(map[string]*store)
+)
+
+// Open 打开内存driver, 相同namespace的driver共享数据, config可以为nil, Config或*Config.
+// 只有首次打开namespace时的配置生效
+func Open(namespace string, config interface{}) (govern.Driver, error) {
+	var cfg Config
+	switch c := config.(type) {
+	case nil:
+	case Config:
+		cfg = c
+	case *Config:
+		cfg = *c
+	default:
+		return nil, fmt.Errorf("unknown %T config type", config)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := stores[namespace]
+	if !ok {
+		s = newStore(cfg)
+		stores[namespace] = s
+	}
+	s.refs++
+	return newDriver(namespace, s, false), nil
+}
+
+// release 释放共享的store, 最后一个driver关闭时停止租约过期检查
+func release(namespace string, s *store) {
+	mu.Lock()
+	defer mu.Unlock()
+	if s.refs--; s.refs > 0 {
+		return
+	}
+	if stores[namespace] == s {
+		delete(stores, namespace)
+	}
+	s.close()
+}
+
+func init() {
+	govern.Register(DriverName, Open)
+}
+
+// Driver 内存driver
+type Driver struct {
+	namespace string
+	store     *store
+	private   bool
+	once      sync.Once
+
+	mu        sync.Mutex
+	providers map[*provider]struct{}
+	consumers map[*consumer]struct{}
+}
+
+// NewDriver 创建不与其它driver共享数据的内存driver
+func NewDriver(namespace string, cfg Config) *Driver {
+	return newDriver(namespace, newStore(cfg), true)
+}
+
+func newDriver(namespace string, s *store, private bool) *Driver {
+	return &Driver{
+		namespace: namespace,
+		store:     s,
+		private:   private,
+		providers: make(map[*provider]struct{}),
+		consumers: make(map[*consumer]struct{}),
+	}
+}
+
+func (d *Driver) Name() string {
+	return DriverName
+}
+
+func (d *Driver) Namespace() string {
+	return d.namespace
+}
+
+// NewProvider 创建provider, 每隔interval续约一次, 租约时间为interval的3倍
+func (d *Driver) NewProvider(service string, interval time.Duration, f govern.GetEndpointFunc) govern.Provider {
+	return d.NewProviderWithTTL(service, 3*interval, interval, f)
+}
+
+// NewProviderWithTTL 创建provider, 每隔interval续约一次, 租约时间为ttl
+func (d *Driver) NewProviderWithTTL(service string, ttl, interval time.Duration, f govern.GetEndpointFunc) govern.Provider {
+	if f == nil {
+		panic("govern.GetEndpointFunc is nil")
+	}
+	p := &provider{
+		driver:   d,
+		service:  d.store.service(service),
+		dir:      d.dir(service),
+		ttl:      ttl,
+		interval: interval,
+		endpoint: f,
+		done:     make(chan struct{}),
+		exited:   make(chan struct{}),
+	}
+	d.mu.Lock()
+	d.providers[p] = struct{}{}
+	d.mu.Unlock()
+	go p.pinging()
+	return p
+}
+
+// NewConsumer 创建consumer, endpoint列表变化时异步回调f
+func (d *Driver) NewConsumer(service string, endpoint govern.Endpoint, f govern.RefreshEndpointsFunc) govern.Consumer {
+	c := d.store.service(service).subscribe(d, d.dir(service), f)
+	d.mu.Lock()
+	d.consumers[c] = struct{}{}
+	d.mu.Unlock()
+	return c
+}
+
+// Close 关闭driver, 先关闭所有provider并等待注销完成, 再关闭consumer,
+// 由NewDriver创建的driver或共享namespace的最后一个driver同时停止租约过期检查
+func (d *Driver) Close() error {
+	d.once.Do(func() {
+		d.mu.Lock()
+		providers, consumers := d.providers, d.consumers
+		d.providers = make(map[*provider]struct{})
+		d.consumers = make(map[*consumer]struct{})
+		d.mu.Unlock()
+
+		for p := range providers {
+			p.Close()
+			<-p.exited
+		}
+		for c := range consumers {
+			c.Close()
+		}
+		if d.private {
+			d.store.close()
+		} else {
+			release(d.namespace, d.store)
+		}
+	})
+	return nil
+}
+
+// Endpoints 返回服务当前注册的endpoint列表, 不受故障注入影响
+func (d *Driver) Endpoints(service string) []govern.Endpoint {
+	return d.store.service(service).endpoints()
+}
+
+// SetFault 设置服务的故障注入, 影响该服务所有的consumer, 零值表示清除故障
+func (d *Driver) SetFault(service string, f Fault) {
+	d.store.service(service).setFault(f)
+}
+
+func (d *Driver) dir(service string) string {
+	return fmt.Sprintf("/%s/%s", d.namespace, service)
+}
+
+// Fault 故障注入选项
+type Fault struct {
+	Drop  bool          // 丢弃endpoint列表的变化, consumer保持旧的列表
+	Delay time.Duration // 延迟通知consumer
+	Flap  time.Duration // 以该间隔交替通知consumer空列表和实际列表
+}
+
+type store struct {
+	cfg  Config
+	done chan struct{}
+	once sync.Once
+	refs int // 共享该store的driver数, 由包级mu保护
+
+	mu       sync.Mutex
+	services map[string]*service
+}
+
+func newStore(cfg Config) *store {
+	cfg.setDefaults()
+	s := &store{
+		cfg:      cfg,
+		done:     make(chan struct{}),
+		services: make(map[string]*service),
+	}
+	go s.expiring()
+	return s
+}
+
+func (s *store) close() {
+	s.once.Do(func() { close(s.done) })
+}
+
+func (s *store) service(name string) *service {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	svc, ok := s.services[name]
+	if !ok {
+		svc = newService()
+		s.services[name] = svc
+	}
+	return svc
+}
+
+func (s *store) expiring() {
+	t := time.NewTicker(s.cfg.ExpireInterval)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			s.mu.Lock()
+			services := make([]*service, 0, len(s.services))
+			for _, svc := range s.services {
+				services = append(services, svc)
+			}
+			s.mu.Unlock()
+			for _, svc := range services {
+				svc.expire(now)
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,226 @@
+package memory
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/x-pearls/govern"
+	"github.com/ironzhang/zerone/pkg/endpoint"
+)
+
+func WaitEndpoints(c govern.Consumer, n int) error {
+	for i := 0; i < 100; i++ {
+		if len(c.GetEndpoints()) == n {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return errors.New("timeout")
+}
+
+func NewTestEndpoint(name string) govern.GetEndpointFunc {
+	return func() govern.Endpoint {
+		return &endpoint.Endpoint{Name: name, Net: "tcp", Addr: "localhost:8000"}
+	}
+}
+
+func TestDriver(t *testing.T) {
+	d := NewDriver("test", Config{})
+	defer d.Close()
+
+	refreshed := make(chan int, 10)
+	c := d.NewConsumer("S", &endpoint.Endpoint{}, func(eps []govern.Endpoint) {
+		refreshed <- len(eps)
+	})
+	defer c.Close()
+
+	p := d.NewProvider("S", time.Second, NewTestEndpoint("0"))
+	if err := WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+	p.Close()
+	if err := WaitEndpoints(c, 0); err != nil {
+		t.Fatalf("wait endpoints after close: %v", err)
+	}
+
+	// 回调只保证通知最新的列表, 最后一次回调为空列表
+	last := -1
+	for i := 0; i < 3; i++ {
+		select {
+		case last = <-refreshed:
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	if last != 0 {
+		t.Errorf("last refreshed: got %v, want 0", last)
+	}
+}
+
+func TestDriverExpire(t *testing.T) {
+	d := NewDriver("test", Config{ExpireInterval: 10 * time.Millisecond})
+	defer d.Close()
+
+	c := d.NewConsumer("S", &endpoint.Endpoint{}, nil)
+	defer c.Close()
+
+	// 续约间隔大于租约时间, 模拟provider异常退出
+	p := d.NewProviderWithTTL("S", 50*time.Millisecond, time.Hour, NewTestEndpoint("0"))
+	defer p.Close()
+	if err := WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+	if err := WaitEndpoints(c, 0); err != nil {
+		t.Fatalf("wait endpoints after expire: %v", err)
+	}
+}
+
+func TestDriverFault(t *testing.T) {
+	d := NewDriver("test", Config{})
+	defer d.Close()
+
+	c := d.NewConsumer("S", &endpoint.Endpoint{}, nil)
+	defer c.Close()
+	p0 := d.NewProvider("S", time.Second, NewTestEndpoint("0"))
+	defer p0.Close()
+	if err := WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+
+	// 丢弃更新
+	d.SetFault("S", Fault{Drop: true})
+	p1 := d.NewProvider("S", time.Second, NewTestEndpoint("1"))
+	defer p1.Close()
+	time.Sleep(50 * time.Millisecond)
+	if got, want := len(c.GetEndpoints()), 1; got != want {
+		t.Errorf("drop: got %v endpoints, want %v", got, want)
+	}
+	if got, want := len(d.Endpoints("S")), 2; got != want {
+		t.Errorf("drop: got %v registered endpoints, want %v", got, want)
+	}
+	d.SetFault("S", Fault{})
+	if err := WaitEndpoints(c, 2); err != nil {
+		t.Fatalf("wait endpoints after drop: %v", err)
+	}
+
+	// 延迟通知
+	d.SetFault("S", Fault{Delay: 100 * time.Millisecond})
+	p1.Close()
+	time.Sleep(50 * time.Millisecond)
+	if got, want := len(c.GetEndpoints()), 2; got != want {
+		t.Errorf("delay: got %v endpoints, want %v", got, want)
+	}
+	if err := WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints after delay: %v", err)
+	}
+
+	// 交替通知
+	d.SetFault("S", Fault{Flap: 20 * time.Millisecond})
+	if err := WaitEndpoints(c, 0); err != nil {
+		t.Fatalf("flap: wait empty endpoints: %v", err)
+	}
+	if err := WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("flap: wait endpoints: %v", err)
+	}
+	d.SetFault("S", Fault{})
+	if err := WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints after flap: %v", err)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	d1, err := govern.Open(DriverName, "TestOpen", nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	d2, err := govern.Open(DriverName, "TestOpen", &Config{})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	d3, err := govern.Open(DriverName, "TestOpen-Other", nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if _, err = govern.Open(DriverName, "TestOpen", "config"); err == nil {
+		t.Errorf("open with invalid config should fail")
+	}
+
+	p := d1.NewProvider("S", time.Second, NewTestEndpoint("0"))
+	defer p.Close()
+	c2 := d2.NewConsumer("S", &endpoint.Endpoint{}, nil)
+	defer c2.Close()
+	c3 := d3.NewConsumer("S", &endpoint.Endpoint{}, nil)
+	defer c3.Close()
+	if err = WaitEndpoints(c2, 1); err != nil {
+		t.Fatalf("wait endpoints in same namespace: %v", err)
+	}
+	if got := len(c3.GetEndpoints()); got != 0 {
+		t.Errorf("got %v endpoints in other namespace", got)
+	}
+}
+
+func TestOpenClose(t *testing.T) {
+	d1, err := Open("TestOpenClose", nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	d2, err := Open("TestOpenClose", nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	s := d1.(*Driver).store
+
+	// 重复关闭只释放一次引用
+	d1.Close()
+	d1.Close()
+	select {
+	case <-s.done:
+		t.Fatalf("store is closed while still in use")
+	default:
+	}
+
+	d2.Close()
+	select {
+	case <-s.done:
+	default:
+		t.Fatalf("store is not closed after the last driver closed")
+	}
+
+	d3, err := Open("TestOpenClose", nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer d3.Close()
+	if d3.(*Driver).store == s {
+		t.Errorf("reopen returns the closed store")
+	}
+}
+
+func TestDriverCloseProviders(t *testing.T) {
+	d1, err := Open("TestDriverCloseProviders", nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	d2, err := Open("TestDriverCloseProviders", nil)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer d2.Close()
+
+	p := d1.NewProvider("S", time.Second, NewTestEndpoint("0")).(*provider)
+	c := d2.NewConsumer("S", &endpoint.Endpoint{}, nil)
+	if err = WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+
+	// 关闭driver时停止provider并注销endpoint
+	d1.Close()
+	select {
+	case <-p.exited:
+	default:
+		t.Fatalf("provider is still pinging after driver closed")
+	}
+	if err = WaitEndpoints(c, 0); err != nil {
+		t.Fatalf("wait endpoints after driver close: %v", err)
+	}
+}
@@ -0,0 +1,279 @@
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ironzhang/x-pearls/govern"
+)
+
+type lease struct {
+	endpoint govern.Endpoint
+	expire   time.Time
+}
+
+type service struct {
+	mu        sync.Mutex
+	leases    map[string]lease
+	consumers map[*consumer]struct{}
+	fault     Fault
+	version   uint64        // 通知的版本, 延迟通知时consumer据此丢弃过期的列表
+	flapping  chan struct{} // 非nil时表示正在交替通知, 关闭以停止
+}
+
+func newService() *service {
+	return &service{
+		leases:    make(map[string]lease),
+		consumers: make(map[*consumer]struct{}),
+	}
+}
+
+func (s *service) set(ep govern.Endpoint, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node := ep.Node()
+	old, ok := s.leases[node]
+	s.leases[node] = lease{endpoint: ep, expire: time.Now().Add(ttl)}
+	if !ok || !old.endpoint.Equal(ep) {
+		s.changed()
+	}
+}
+
+func (s *service) remove(node string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.leases[node]; ok {
+		delete(s.leases, node)
+		s.changed()
+	}
+}
+
+func (s *service) expire(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed := false
+	for node, l := range s.leases {
+		if now.After(l.expire) {
+			delete(s.leases, node)
+			changed = true
+		}
+	}
+	if changed {
+		s.changed()
+	}
+}
+
+func (s *service) endpoints() []govern.Endpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list()
+}
+
+func (s *service) list() []govern.Endpoint {
+	m := make(govern.Endpoints, len(s.leases))
+	for _, l := range s.leases {
+		m.Add(l.endpoint)
+	}
+	return m.SortList()
+}
+
+// changed 按故障注入的设置通知所有consumer, 调用时需持有锁
+func (s *service) changed() {
+	if s.fault.Drop || s.flapping != nil {
+		return
+	}
+	s.notify(s.list())
+}
+
+func (s *service) notify(eps []govern.Endpoint) {
+	s.version++
+	version, delay := s.version, s.fault.Delay
+	for c := range s.consumers {
+		if delay > 0 {
+			c := c
+			time.AfterFunc(delay, func() { c.push(version, eps) })
+		} else {
+			c.push(version, eps)
+		}
+	}
+}
+
+func (s *service) setFault(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.flapping != nil {
+		close(s.flapping)
+		s.flapping = nil
+	}
+	s.fault = f
+	if f.Flap > 0 {
+		s.flapping = make(chan struct{})
+		go s.flap(f.Flap, s.flapping)
+		return
+	}
+	if !f.Drop {
+		s.notify(s.list())
+	}
+}
+
+func (s *service) flap(interval time.Duration, done <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for empty := true; ; empty = !empty {
+		select {
+		case <-t.C:
+		case <-done:
+			return
+		}
+		s.mu.Lock()
+		if empty {
+			s.notify(nil)
+		} else {
+			s.notify(s.list())
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *service) subscribe(d *Driver, dir string, f govern.RefreshEndpointsFunc) *consumer {
+	c := newConsumer(d, s, dir, f)
+	s.mu.Lock()
+	s.consumers[c] = struct{}{}
+	c.push(s.version, s.list())
+	s.mu.Unlock()
+	return c
+}
+
+func (s *service) unsubscribe(c *consumer) {
+	s.mu.Lock()
+	delete(s.consumers, c)
+	s.mu.Unlock()
+}
+
+type provider struct {
+	driver    *Driver
+	service   *service
+	dir       string
+	ttl       time.Duration
+	interval  time.Duration
+	endpoint  govern.GetEndpointFunc
+	done      chan struct{}
+	exited    chan struct{}
+	closeOnce sync.Once
+}
+
+func (p *provider) Driver() string {
+	return DriverName
+}
+
+func (p *provider) Directory() string {
+	return p.dir
+}
+
+func (p *provider) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.driver.mu.Lock()
+		delete(p.driver.providers, p)
+		p.driver.mu.Unlock()
+	})
+	return nil
+}
+
+func (p *provider) pinging() {
+	defer close(p.exited)
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+
+	p.service.set(p.endpoint(), p.ttl)
+	for {
+		select {
+		case <-t.C:
+			p.service.set(p.endpoint(), p.ttl)
+		case <-p.done:
+			p.service.remove(p.endpoint().Node())
+			return
+		}
+	}
+}
+
+// consumer 在独立的goroutine中回调, 只通知最新的endpoint列表
+type consumer struct {
+	driver    *Driver
+	service   *service
+	dir       string
+	refresh   govern.RefreshEndpointsFunc
+	signal    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu      sync.Mutex
+	version uint64
+	list    []govern.Endpoint
+}
+
+func newConsumer(d *Driver, s *service, dir string, f govern.RefreshEndpointsFunc) *consumer {
+	c := &consumer{
+		driver:  d,
+		service: s,
+		dir:     dir,
+		refresh: f,
+		signal:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go c.refreshing()
+	return c
+}
+
+func (c *consumer) Driver() string {
+	return DriverName
+}
+
+func (c *consumer) Directory() string {
+	return c.dir
+}
+
+func (c *consumer) Close() error {
+	c.closeOnce.Do(func() {
+		c.service.unsubscribe(c)
+		close(c.done)
+		c.driver.mu.Lock()
+		delete(c.driver.consumers, c)
+		c.driver.mu.Unlock()
+	})
+	return nil
+}
+
+func (c *consumer) GetEndpoints() []govern.Endpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.list
+}
+
+func (c *consumer) push(version uint64, eps []govern.Endpoint) {
+	c.mu.Lock()
+	if version < c.version {
+		c.mu.Unlock()
+		return
+	}
+	c.version, c.list = version, eps
+	c.mu.Unlock()
+
+	select {
+	case c.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (c *consumer) refreshing() {
+	for {
+		select {
+		case <-c.signal:
+		case <-c.done:
+			return
+		}
+		if c.refresh != nil {
+			c.refresh(c.GetEndpoints())
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package registry
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ironzhang/zerone/rpc"
+)
+
+// 重连退避常量定义
+const (
+	minRedialDelay = 100 * time.Millisecond
+	maxRedialDelay = 10 * time.Second
+)
+
+// client 到注册中心的连接, 连接不可用时按指数退避重连
+type client struct {
+	network string
+	address string
+	timeout time.Duration
+
+	mu      sync.Mutex
+	rc      *rpc.Client
+	closed  bool
+	delay   time.Duration
+	retryAt time.Time
+}
+
+func newClient(network, address string, timeout time.Duration) *client {
+	return &client{network: network, address: address, timeout: timeout}
+}
+
+// get 返回可用的连接, 连接不可用时重连, 退避期间返回rpc.ErrUnavailable
+func (c *client) get() (*rpc.Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return nil, rpc.ErrShutdown
+	}
+	if c.rc != nil {
+		if c.rc.IsAvailable() {
+			return c.rc, nil
+		}
+		c.rc.Close()
+		c.rc = nil
+	}
+	now := time.Now()
+	if now.Before(c.retryAt) {
+		return nil, rpc.ErrUnavailable
+	}
+	rc, err := rpc.DialWithOptions(DriverName, c.network, c.address, rpc.DialOptions{Timeout: c.timeout})
+	if err != nil {
+		if c.delay *= 2; c.delay < minRedialDelay {
+			c.delay = minRedialDelay
+		} else if c.delay > maxRedialDelay {
+			c.delay = maxRedialDelay
+		}
+		c.retryAt = now.Add(c.delay)
+		return nil, err
+	}
+	c.delay = 0
+	c.rc = rc
+	return rc, nil
+}
+
+func (c *client) Call(ctx context.Context, method string, args, reply interface{}, timeout time.Duration) error {
+	rc, err := c.get()
+	if err != nil {
+		return err
+	}
+	return rc.Call(ctx, method, args, reply, timeout)
+}
+
+// Close 关闭连接, 进行中的调用立即返回
+func (c *client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.closed {
+		return rpc.ErrShutdown
+	}
+	c.closed = true
+	if c.rc != nil {
+		return c.rc.Close()
+	}
+	return nil
+}
@@ -0,0 +1,352 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	log "github.com/ironzhang/tlog"
+	"github.com/ironzhang/x-pearls/govern"
+)
+
+// DriverName 注册中心driver的名称
+const DriverName = "zerone-registry"
+
+// Config 注册中心driver配置
+type Config struct {
+	Network      string        // 注册中心的网络类型, 默认为tcp
+	Address      string        // 注册中心的地址
+	Timeout      time.Duration // 注册请求的超时时间, 默认为3s
+	WatchTimeout time.Duration // 每次订阅请求的最长等待时间, 默认为30s
+}
+
+func (c *Config) setDefaults() {
+	if c.Network == "" {
+		c.Network = "tcp"
+	}
+	if c.Timeout <= 0 {
+		c.Timeout = 3 * time.Second
+	}
+	if c.WatchTimeout <= 0 {
+		c.WatchTimeout = defaultWatchTimeout
+	}
+}
+
+// Driver 注册中心的govern.Driver实现
+type Driver struct {
+	namespace string
+	cfg       Config
+	client    *client
+
+	mu        sync.Mutex
+	providers map[*provider]struct{}
+	consumers map[*consumer]struct{}
+}
+
+// NewDriver 创建连接到注册中心的driver
+func NewDriver(namespace string, cfg Config) *Driver {
+	cfg.setDefaults()
+	return &Driver{
+		namespace: namespace,
+		cfg:       cfg,
+		client:    newClient(cfg.Network, cfg.Address, cfg.Timeout),
+		providers: make(map[*provider]struct{}),
+		consumers: make(map[*consumer]struct{}),
+	}
+}
+
+// Open 打开注册中心driver, config为Config或*Config
+func Open(namespace string, config interface{}) (govern.Driver, error) {
+	switch cfg := config.(type) {
+	case Config:
+		return NewDriver(namespace, cfg), nil
+	case *Config:
+		return NewDriver(namespace, *cfg), nil
+	default:
+		return nil, fmt.Errorf("unknown %T config type", config)
+	}
+}
+
+func init() {
+	govern.Register(DriverName, Open)
+}
+
+func (d *Driver) Name() string {
+	return DriverName
+}
+
+func (d *Driver) Namespace() string {
+	return d.namespace
+}
+
+// NewProvider 创建provider, 每隔interval续约一次, 租约时间为interval的3倍
+func (d *Driver) NewProvider(service string, interval time.Duration, f govern.GetEndpointFunc) govern.Provider {
+	return d.NewProviderWithTTL(service, 3*interval, interval, f)
+}
+
+// NewProviderWithTTL 创建provider, 每隔interval续约一次, 租约时间为ttl
+func (d *Driver) NewProviderWithTTL(service string, ttl, interval time.Duration, f govern.GetEndpointFunc) govern.Provider {
+	if f == nil {
+		panic("govern.GetEndpointFunc is nil")
+	}
+	p := &provider{
+		driver:   d,
+		service:  service,
+		ttl:      ttl,
+		interval: interval,
+		endpoint: f,
+		done:     make(chan struct{}),
+		exited:   make(chan struct{}),
+	}
+	d.mu.Lock()
+	d.providers[p] = struct{}{}
+	d.mu.Unlock()
+	go p.pinging()
+	return p
+}
+
+// NewConsumer 创建consumer, endpoint为服务endpoint的原型, 用于解析注册信息
+func (d *Driver) NewConsumer(service string, endpoint govern.Endpoint, f govern.RefreshEndpointsFunc) govern.Consumer {
+	typ := reflect.TypeOf(endpoint)
+	if typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	c := &consumer{
+		driver:  d,
+		service: service,
+		typ:     typ,
+		refresh: f,
+		done:    make(chan struct{}),
+		exited:  make(chan struct{}),
+	}
+	d.mu.Lock()
+	d.consumers[c] = struct{}{}
+	d.mu.Unlock()
+	go c.watching()
+	return c
+}
+
+// Close 关闭driver, 先关闭所有provider并等待注销完成, 再关闭连接并等待consumer退出
+func (d *Driver) Close() error {
+	d.mu.Lock()
+	providers, consumers := d.providers, d.consumers
+	d.providers = make(map[*provider]struct{})
+	d.consumers = make(map[*consumer]struct{})
+	d.mu.Unlock()
+
+	for p := range providers {
+		p.Close()
+		<-p.exited
+	}
+	for c := range consumers {
+		c.Close()
+	}
+	// 关闭连接使等待中的Watch立即返回
+	err := d.client.Close()
+	for c := range consumers {
+		<-c.exited
+	}
+	return err
+}
+
+func (d *Driver) dir(service string) string {
+	return fmt.Sprintf("/%s/%s", d.namespace, service)
+}
+
+type provider struct {
+	driver    *Driver
+	service   string
+	ttl       time.Duration
+	interval  time.Duration
+	endpoint  govern.GetEndpointFunc
+	done      chan struct{}
+	exited    chan struct{}
+	closeOnce sync.Once
+}
+
+func (p *provider) Driver() string {
+	return DriverName
+}
+
+func (p *provider) Directory() string {
+	return p.driver.dir(p.service)
+}
+
+func (p *provider) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.done)
+		p.driver.mu.Lock()
+		delete(p.driver.providers, p)
+		p.driver.mu.Unlock()
+	})
+	return nil
+}
+
+func (p *provider) pinging() {
+	defer close(p.exited)
+	t := time.NewTicker(p.interval)
+	defer t.Stop()
+
+	p.register()
+	for {
+		select {
+		case <-t.C:
+			p.register()
+		case <-p.done:
+			p.deregister()
+			return
+		}
+	}
+}
+
+func (p *provider) register() {
+	ep := p.endpoint()
+	value, err := json.Marshal(ep)
+	if err != nil {
+		log.Warnf("marshal endpoint: dir=%s, endpoint=%v: %v", p.Directory(), ep, err)
+		return
+	}
+	args := RegisterArgs{
+		Namespace: p.driver.namespace,
+		Service:   p.service,
+		Node:      ep.Node(),
+		Endpoint:  value,
+		TTL:       p.ttl,
+	}
+	if err = p.driver.client.Call(context.Background(), "Registry.Register", args, nil, p.driver.cfg.Timeout); err != nil {
+		log.Warnf("register endpoint: dir=%s, endpoint=%v: %v", p.Directory(), ep, err)
+		return
+	}
+	log.Debugf("register endpoint: dir=%s, endpoint=%v", p.Directory(), ep)
+}
+
+func (p *provider) deregister() {
+	ep := p.endpoint()
+	args := DeregisterArgs{
+		Namespace: p.driver.namespace,
+		Service:   p.service,
+		Node:      ep.Node(),
+	}
+	if err := p.driver.client.Call(context.Background(), "Registry.Deregister", args, nil, p.driver.cfg.Timeout); err != nil {
+		log.Warnf("deregister endpoint: dir=%s, endpoint=%v: %v", p.Directory(), ep, err)
+		return
+	}
+	log.Debugf("deregister endpoint: dir=%s, endpoint=%v", p.Directory(), ep)
+}
+
+type consumer struct {
+	driver    *Driver
+	service   string
+	typ       reflect.Type
+	refresh   govern.RefreshEndpointsFunc
+	done      chan struct{}
+	exited    chan struct{}
+	closeOnce sync.Once
+
+	mu   sync.RWMutex
+	list []govern.Endpoint
+}
+
+func (c *consumer) Driver() string {
+	return DriverName
+}
+
+func (c *consumer) Directory() string {
+	return c.driver.dir(c.service)
+}
+
+func (c *consumer) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.driver.mu.Lock()
+		delete(c.driver.consumers, c)
+		c.driver.mu.Unlock()
+	})
+	return nil
+}
+
+func (c *consumer) GetEndpoints() []govern.Endpoint {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.list
+}
+
+// watching 以长轮询的方式订阅服务的endpoint列表, 服务变化时注册中心立即返回
+func (c *consumer) watching() {
+	defer close(c.exited)
+	const min, max = 100 * time.Millisecond, 10 * time.Second
+
+	delay := min
+	revision := uint64(0)
+	for {
+		select {
+		case <-c.done:
+			return
+		default:
+		}
+
+		args := WatchArgs{
+			Namespace: c.driver.namespace,
+			Service:   c.service,
+			Revision:  revision,
+			Timeout:   c.driver.cfg.WatchTimeout,
+		}
+		var reply WatchReply
+		timeout := c.driver.cfg.WatchTimeout + c.driver.cfg.Timeout
+		if err := c.driver.client.Call(context.Background(), "Registry.Watch", args, &reply, timeout); err != nil {
+			log.Warnf("watch endpoints: dir=%s, delay=%v: %v", c.Directory(), delay, err)
+			select {
+			case <-time.After(delay):
+			case <-c.done:
+				return
+			}
+			if delay *= 2; delay > max {
+				delay = max
+			}
+			continue
+		}
+		delay = min
+
+		if reply.Revision == revision {
+			continue
+		}
+		revision = reply.Revision
+		eps, err := c.decode(reply.Endpoints)
+		if err != nil {
+			log.Warnf("decode endpoints: dir=%s: %v", c.Directory(), err)
+			continue
+		}
+		c.doRefresh(eps)
+	}
+}
+
+func (c *consumer) decode(values []json.RawMessage) ([]govern.Endpoint, error) {
+	eps := make([]govern.Endpoint, 0, len(values))
+	for _, value := range values {
+		ep := reflect.New(c.typ).Interface().(govern.Endpoint)
+		if err := json.Unmarshal(value, ep); err != nil {
+			return nil, err
+		}
+		eps = append(eps, ep)
+	}
+	return eps, nil
+}
+
+func (c *consumer) doRefresh(eps []govern.Endpoint) {
+	log.Debugf("refresh endpoints: dir=%s, endpoints=%v", c.Directory(), eps)
+	c.mu.Lock()
+	c.list = eps
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return
+	default:
+	}
+	if c.refresh != nil {
+		c.refresh(eps)
+	}
+}
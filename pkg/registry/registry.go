@@ -0,0 +1,234 @@
+// Package registry 轻量的服务注册中心, 服务端以租约保存服务的endpoint, 客户端实现了govern.Driver
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTTL          = 30 * time.Second
+	defaultWatchTimeout = 30 * time.Second
+	maxWatchTimeout     = 5 * time.Minute
+	expireInterval      = 100 * time.Millisecond
+)
+
+// RegisterArgs 注册参数
+type RegisterArgs struct {
+	Namespace string
+	Service   string
+	Node      string
+	Endpoint  json.RawMessage
+	TTL       time.Duration // 租约时间, 超过该时间没有续约则删除, 默认为30s
+}
+
+// DeregisterArgs 注销参数
+type DeregisterArgs struct {
+	Namespace string
+	Service   string
+	Node      string
+}
+
+// WatchArgs 订阅参数, Revision与服务当前版本不同时立即返回, 否则等待服务变化或超时
+type WatchArgs struct {
+	Namespace string
+	Service   string
+	Revision  uint64
+	Timeout   time.Duration // 等待超时时间, 默认为30s
+}
+
+// WatchReply 订阅结果
+type WatchReply struct {
+	Revision  uint64
+	Endpoints []json.RawMessage
+}
+
+type lease struct {
+	value  json.RawMessage
+	expire time.Time
+}
+
+type service struct {
+	revision uint64
+	leases   map[string]lease
+	changed  chan struct{}
+	watchers int // 等待中的Watch数
+}
+
+// Registry 注册中心服务, 通过zserver.Server.Register注册后对外提供服务
+type Registry struct {
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu       sync.Mutex
+	revision uint64
+	services map[string]*service
+}
+
+// New 创建注册中心
+func New() *Registry {
+	r := &Registry{
+		done: make(chan struct{}),
+		// 以启动时间作为初始版本, 注册中心重启后版本不会回退
+		revision: uint64(time.Now().UnixNano()),
+		services: make(map[string]*service),
+	}
+	go r.expiring()
+	return r
+}
+
+// Close 关闭注册中心, 停止租约过期检查
+func (r *Registry) Close() error {
+	r.closeOnce.Do(func() { close(r.done) })
+	return nil
+}
+
+// Register 注册或续约endpoint
+func (r *Registry) Register(ctx context.Context, args RegisterArgs, reply interface{}) error {
+	if args.Service == "" || args.Node == "" {
+		return errors.New("service or node is empty")
+	}
+	ttl := args.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	svc := r.service(args.Namespace, args.Service)
+	old, ok := svc.leases[args.Node]
+	svc.leases[args.Node] = lease{value: args.Endpoint, expire: time.Now().Add(ttl)}
+	if !ok || string(old.value) != string(args.Endpoint) {
+		r.change(svc)
+	}
+	return nil
+}
+
+// Deregister 注销endpoint
+func (r *Registry) Deregister(ctx context.Context, args DeregisterArgs, reply interface{}) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := serviceKey(args.Namespace, args.Service)
+	svc, ok := r.services[key]
+	if !ok {
+		return nil
+	}
+	if _, ok = svc.leases[args.Node]; ok {
+		delete(svc.leases, args.Node)
+		r.change(svc)
+	}
+	r.release(key, svc)
+	return nil
+}
+
+// Watch 获取服务的endpoint列表, 列表没有变化时等待直到变化或超时
+func (r *Registry) Watch(ctx context.Context, args WatchArgs, reply *WatchReply) error {
+	timeout := args.Timeout
+	if timeout <= 0 {
+		timeout = defaultWatchTimeout
+	} else if timeout > maxWatchTimeout {
+		timeout = maxWatchTimeout
+	}
+
+	key := serviceKey(args.Namespace, args.Service)
+	r.mu.Lock()
+	svc := r.service(args.Namespace, args.Service)
+	if svc.revision == args.Revision {
+		changed := svc.changed
+		svc.watchers++
+		r.mu.Unlock()
+
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		select {
+		case <-changed:
+		case <-t.C:
+		case <-ctx.Done():
+		case <-r.done:
+		}
+		r.mu.Lock()
+		svc.watchers--
+	}
+	defer r.mu.Unlock()
+	defer r.release(key, svc)
+
+	nodes := make([]string, 0, len(svc.leases))
+	for node := range svc.leases {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+	reply.Revision = svc.revision
+	reply.Endpoints = make([]json.RawMessage, 0, len(nodes))
+	for _, node := range nodes {
+		reply.Endpoints = append(reply.Endpoints, svc.leases[node].value)
+	}
+	return nil
+}
+
+func serviceKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+func (r *Registry) service(namespace, name string) *service {
+	key := serviceKey(namespace, name)
+	svc, ok := r.services[key]
+	if !ok {
+		svc = &service{
+			revision: r.revision,
+			leases:   make(map[string]lease),
+			changed:  make(chan struct{}),
+		}
+		r.services[key] = svc
+	}
+	return svc
+}
+
+// release 服务没有endpoint且没有等待中的Watch时删除服务, 避免services无限增长
+func (r *Registry) release(key string, svc *service) {
+	if len(svc.leases) <= 0 && svc.watchers <= 0 && r.services[key] == svc {
+		delete(r.services, key)
+	}
+}
+
+// change 更新服务版本并唤醒等待中的Watch
+func (r *Registry) change(svc *service) {
+	r.revision++
+	svc.revision = r.revision
+	close(svc.changed)
+	svc.changed = make(chan struct{})
+}
+
+func (r *Registry) expiring() {
+	t := time.NewTicker(expireInterval)
+	defer t.Stop()
+	for {
+		select {
+		case now := <-t.C:
+			r.expire(now)
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *Registry) expire(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, svc := range r.services {
+		changed := false
+		for node, l := range svc.leases {
+			if now.After(l.expire) {
+				delete(svc.leases, node)
+				changed = true
+			}
+		}
+		if changed {
+			r.change(svc)
+		}
+		r.release(key, svc)
+	}
+}
@@ -0,0 +1,190 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/x-pearls/govern"
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/rpc"
+)
+
+func ServeRegistry(t *testing.T) (net.Listener, string) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	s := rpc.NewServer("TestRegistry")
+	if err = s.Register(New()); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	go s.Accept(ln)
+	return ln, ln.Addr().String()
+}
+
+func WaitEndpoints(c govern.Consumer, n int) error {
+	for i := 0; i < 100; i++ {
+		if len(c.GetEndpoints()) == n {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return errors.New("timeout")
+}
+
+func TestRegistryExpire(t *testing.T) {
+	r := New()
+	defer r.Close()
+
+	args := RegisterArgs{Service: "S", Node: "0", Endpoint: []byte(`{"Name":"0"}`), TTL: 50 * time.Millisecond}
+	if err := r.Register(context.Background(), args, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	var reply WatchReply
+	if err := r.Watch(context.Background(), WatchArgs{Service: "S"}, &reply); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if got, want := len(reply.Endpoints), 1; got != want {
+		t.Fatalf("endpoints: got %v, want %v", got, want)
+	}
+
+	// 租约过期后唤醒Watch
+	revision := reply.Revision
+	if err := r.Watch(context.Background(), WatchArgs{Service: "S", Revision: revision, Timeout: time.Second}, &reply); err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+	if reply.Revision == revision {
+		t.Errorf("revision is not changed after expire")
+	}
+	if got, want := len(reply.Endpoints), 0; got != want {
+		t.Errorf("endpoints: got %v, want %v", got, want)
+	}
+	if got, want := len(r.services), 0; got != want {
+		t.Errorf("services: got %v, want %v", got, want)
+	}
+}
+
+func TestRegistryDeregister(t *testing.T) {
+	r := New()
+	defer r.Close()
+
+	args := RegisterArgs{Service: "S", Node: "0", Endpoint: []byte(`{"Name":"0"}`)}
+	if err := r.Register(context.Background(), args, nil); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := r.Deregister(context.Background(), DeregisterArgs{Service: "S", Node: "0"}, nil); err != nil {
+		t.Fatalf("deregister: %v", err)
+	}
+	// 最后一个endpoint注销后删除服务
+	if got, want := len(r.services), 0; got != want {
+		t.Errorf("services: got %v, want %v", got, want)
+	}
+}
+
+func TestDriverClose(t *testing.T) {
+	s, addr := ServeRegistry(t)
+	defer s.Close()
+
+	cfg := Config{Address: addr, WatchTimeout: time.Second}
+	d1 := NewDriver("test", cfg)
+	d2 := NewDriver("test", cfg)
+	defer d2.Close()
+
+	c := d2.NewConsumer("TestDriverClose", &endpoint.Endpoint{}, nil)
+	d1.NewProvider("TestDriverClose", time.Second, func() govern.Endpoint {
+		return &endpoint.Endpoint{Name: "0", Net: "tcp", Addr: "localhost:8000"}
+	})
+	if err := WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+
+	// 关闭driver时先注销provider再关闭连接
+	d1.Close()
+	if err := WaitEndpoints(c, 0); err != nil {
+		t.Fatalf("wait endpoints after driver close: %v", err)
+	}
+}
+
+func TestDriver(t *testing.T) {
+	s, addr := ServeRegistry(t)
+	defer s.Close()
+
+	d, err := govern.Open(DriverName, "test", Config{Address: addr, WatchTimeout: time.Second})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer d.Close()
+
+	refreshed := make(chan int, 10)
+	c := d.NewConsumer("TestDriver", &endpoint.Endpoint{}, func(eps []govern.Endpoint) {
+		refreshed <- len(eps)
+	})
+	defer c.Close()
+	if got := <-refreshed; got != 0 {
+		t.Errorf("refreshed: got %v, want 0", got)
+	}
+
+	p := d.NewProvider("TestDriver", time.Second, func() govern.Endpoint {
+		return &endpoint.Endpoint{Name: "0", Net: "tcp", Addr: "localhost:8000"}
+	})
+	if err = WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+	if ep, ok := c.GetEndpoints()[0].(*endpoint.Endpoint); !ok || ep.Addr != "localhost:8000" {
+		t.Errorf("endpoint: got %v", c.GetEndpoints()[0])
+	}
+	p.Close()
+	if err = WaitEndpoints(c, 0); err != nil {
+		t.Fatalf("wait endpoints after close: %v", err)
+	}
+
+	for _, want := range []int{1, 0} {
+		select {
+		case got := <-refreshed:
+			if got != want {
+				t.Errorf("refreshed: got %v, want %v", got, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("refreshed: timeout")
+		}
+	}
+}
+
+func TestClientRedial(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	c := newClient("tcp", addr, time.Second)
+	defer c.Close()
+	if err = c.Call(context.Background(), "Registry.Watch", WatchArgs{}, nil, time.Second); err == nil || err == rpc.ErrUnavailable {
+		t.Fatalf("call: got %v, want dial error", err)
+	}
+	// 退避期间不重连
+	if err = c.Call(context.Background(), "Registry.Watch", WatchArgs{}, nil, time.Second); err != rpc.ErrUnavailable {
+		t.Errorf("call during backoff: got %v, want %v", err, rpc.ErrUnavailable)
+	}
+
+	// 注册中心启动后重连成功
+	ln, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	s := rpc.NewServer("TestRegistry")
+	if err = s.Register(New()); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	go s.Accept(ln)
+	time.Sleep(minRedialDelay)
+	var reply WatchReply
+	if err = c.Call(context.Background(), "Registry.Watch", WatchArgs{Service: "S"}, &reply, time.Second); err != nil {
+		t.Errorf("call after redial: %v", err)
+	}
+}
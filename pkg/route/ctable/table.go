@@ -0,0 +1,144 @@
+// Package ctable 合并多个数据源的路由表, 如静态路由文件和服务发现, 或者多个命名空间的服务发现
+package ctable
+
+import (
+	"io"
+	"sync"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
+)
+
+var _ route.Table = &Table{}
+
+var _ route.Watchable = &Table{}
+
+// Mode 合并模式
+type Mode int
+
+// 合并模式常量定义
+const (
+	Merge    Mode = iota // 合并所有数据源的endpoint
+	Fallback             // 只使用第一个endpoint列表非空的数据源
+)
+
+// Source 数据源
+type Source struct {
+	Table    route.Table
+	Override func(ep endpoint.Endpoint) endpoint.Endpoint // 修改该数据源的endpoint, 如设置Tags或Load, 为nil时不修改
+	Owned    bool                                         // 是否由合并路由表持有, 持有的数据源在Close时一并关闭
+}
+
+// Options 合并选项
+type Options struct {
+	Mode            Mode // 合并模式, 默认为Merge
+	AllowDuplicates bool // 是否保留同名的endpoint, 默认按Name去重, 保留优先级高的数据源的endpoint. 保留时Watch事件仍按Name计算
+}
+
+// Table 合并多个数据源的路由表, 数据源按优先级从高到低排列.
+// 所有数据源都实现了route.Watchable时, 数据源变化后立即更新, 否则在ListEndpoints时更新
+type Table struct {
+	opts     Options
+	sources  []Source
+	watched  bool
+	cancels  []func()
+	notifier route.Notifier
+	once     sync.Once
+
+	mu        sync.Mutex
+	endpoints []endpoint.Endpoint
+}
+
+// NewTable 创建合并路由表
+func NewTable(opts Options, sources ...Source) *Table {
+	return new(Table).init(opts, sources)
+}
+
+func (t *Table) init(opts Options, sources []Source) *Table {
+	t.opts = opts
+	t.sources = sources
+	t.watched = true
+	for _, s := range sources {
+		w, ok := s.Table.(route.Watchable)
+		if !ok {
+			t.watched = false
+			continue
+		}
+		t.cancels = append(t.cancels, w.Watch(func(route.Event) { t.update() }))
+	}
+	t.update()
+	return t
+}
+
+// Close 取消对数据源的订阅, 并关闭Owned的数据源
+func (t *Table) Close() (err error) {
+	t.once.Do(func() {
+		for _, cancel := range t.cancels {
+			cancel()
+		}
+		for _, s := range t.sources {
+			if !s.Owned {
+				continue
+			}
+			if c, ok := s.Table.(io.Closer); ok {
+				if e := c.Close(); e != nil && err == nil {
+					err = e
+				}
+			}
+		}
+	})
+	return err
+}
+
+func (t *Table) ListEndpoints() []endpoint.Endpoint {
+	if !t.watched {
+		return t.update()
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.endpoints
+}
+
+func (t *Table) Watch(f route.WatchFunc) (cancel func()) {
+	return t.notifier.Watch(f)
+}
+
+// update 重新合并数据源, 有变化时通知订阅者
+func (t *Table) update() (eps []endpoint.Endpoint) {
+	t.notifier.Update(func() route.Event {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		eps = t.merge()
+		e := route.Diff(t.endpoints, eps)
+		t.endpoints = eps
+		return e
+	})
+	return eps
+}
+
+func (t *Table) merge() []endpoint.Endpoint {
+	var eps []endpoint.Endpoint
+	seen := make(map[string]bool)
+	for _, s := range t.sources {
+		list := s.Table.ListEndpoints()
+		if t.opts.Mode == Fallback && len(list) == 0 {
+			continue
+		}
+		for _, ep := range list {
+			if s.Override != nil {
+				ep = s.Override(ep)
+			}
+			if !t.opts.AllowDuplicates {
+				if seen[ep.Name] {
+					continue
+				}
+				seen[ep.Name] = true
+			}
+			eps = append(eps, ep)
+		}
+		if t.opts.Mode == Fallback {
+			break
+		}
+	}
+	return eps
+}
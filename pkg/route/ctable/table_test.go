@@ -0,0 +1,150 @@
+package ctable
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
+)
+
+type StaticTable []endpoint.Endpoint
+
+func (t StaticTable) ListEndpoints() []endpoint.Endpoint {
+	return t
+}
+
+type WatchableTable struct {
+	route.Notifier
+	endpoints []endpoint.Endpoint
+}
+
+func (t *WatchableTable) ListEndpoints() []endpoint.Endpoint {
+	return t.endpoints
+}
+
+func (t *WatchableTable) setEndpoints(eps []endpoint.Endpoint) {
+	e := route.Diff(t.endpoints, eps)
+	t.endpoints = eps
+	t.Notify(e)
+}
+
+func names(eps []endpoint.Endpoint) []string {
+	s := make([]string, 0, len(eps))
+	for _, ep := range eps {
+		s = append(s, ep.Name+"@"+ep.Addr)
+	}
+	return s
+}
+
+func TestTable(t *testing.T) {
+	primary := StaticTable{
+		{Name: "a", Addr: "1"},
+		{Name: "b", Addr: "1"},
+	}
+	secondary := StaticTable{
+		{Name: "b", Addr: "2"},
+		{Name: "c", Addr: "2"},
+	}
+
+	tests := []struct {
+		opts    Options
+		sources []Source
+		want    []string
+	}{
+		{
+			opts:    Options{Mode: Merge},
+			sources: []Source{{Table: primary}, {Table: secondary}},
+			want:    []string{"a@1", "b@1", "c@2"},
+		},
+		{
+			opts:    Options{Mode: Merge},
+			sources: []Source{{Table: secondary}, {Table: primary}},
+			want:    []string{"b@2", "c@2", "a@1"},
+		},
+		{
+			opts:    Options{Mode: Merge, AllowDuplicates: true},
+			sources: []Source{{Table: primary}, {Table: secondary}},
+			want:    []string{"a@1", "b@1", "b@2", "c@2"},
+		},
+		{
+			opts:    Options{Mode: Fallback},
+			sources: []Source{{Table: primary}, {Table: secondary}},
+			want:    []string{"a@1", "b@1"},
+		},
+		{
+			opts:    Options{Mode: Fallback},
+			sources: []Source{{Table: StaticTable{}}, {Table: secondary}},
+			want:    []string{"b@2", "c@2"},
+		},
+		{
+			opts: Options{Mode: Merge},
+			sources: []Source{
+				{Table: primary},
+				{Table: secondary, Override: func(ep endpoint.Endpoint) endpoint.Endpoint {
+					ep.Addr = "x"
+					return ep
+				}},
+			},
+			want: []string{"a@1", "b@1", "c@x"},
+		},
+	}
+	for i, tt := range tests {
+		tb := NewTable(tt.opts, tt.sources...)
+		if got := names(tb.ListEndpoints()); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%d: got %v, want %v", i, got, tt.want)
+		}
+		tb.Close()
+	}
+}
+
+func TestTableWatch(t *testing.T) {
+	primary := &WatchableTable{}
+	secondary := &WatchableTable{}
+	secondary.setEndpoints([]endpoint.Endpoint{{Name: "a", Addr: "2"}})
+
+	tb := NewTable(Options{Mode: Merge}, Source{Table: primary}, Source{Table: secondary})
+	defer tb.Close()
+
+	var events []route.Event
+	cancel := tb.Watch(func(e route.Event) { events = append(events, e) })
+	defer cancel()
+
+	primary.setEndpoints([]endpoint.Endpoint{{Name: "a", Addr: "1"}, {Name: "b", Addr: "1"}})
+	if got, want := names(tb.ListEndpoints()), []string{"a@1", "b@1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("endpoints: got %v, want %v", got, want)
+	}
+	if len(events) != 1 || len(events[0].Added) != 1 || len(events[0].Updated) != 1 {
+		t.Errorf("events: got %+v", events)
+	}
+
+	// 优先级低的数据源中被覆盖的endpoint变化时不通知
+	secondary.setEndpoints([]endpoint.Endpoint{{Name: "a", Addr: "3"}})
+	if len(events) != 1 {
+		t.Errorf("events: got %+v", events)
+	}
+}
+
+type ClosableTable struct {
+	StaticTable
+	closed int
+}
+
+func (t *ClosableTable) Close() error {
+	t.closed++
+	return nil
+}
+
+func TestTableClose(t *testing.T) {
+	owned := &ClosableTable{}
+	shared := &ClosableTable{}
+	tb := NewTable(Options{}, Source{Table: owned, Owned: true}, Source{Table: shared})
+	tb.Close()
+	tb.Close()
+	if got, want := owned.closed, 1; got != want {
+		t.Errorf("owned closed: got %v, want %v", got, want)
+	}
+	if got, want := shared.closed, 0; got != want {
+		t.Errorf("shared closed: got %v, want %v", got, want)
+	}
+}
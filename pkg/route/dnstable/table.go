@@ -0,0 +1,189 @@
+package dnstable
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/ironzhang/tlog"
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
+)
+
+// DNS解析常量定义
+const (
+	DefaultInterval = 30 * time.Second // 默认的DNS刷新间隔
+	DefaultTimeout  = 5 * time.Second  // 默认的单次解析超时时间, 不超过刷新间隔
+)
+
+// Resolver DNS解析器, *net.Resolver实现了该接口
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// Record 服务对应的DNS记录
+type Record struct {
+	Net  string // 网络类型, 默认为tcp
+	Host string // A/AAAA记录为域名, SRV记录为完整的SRV名称, 如: _arith._tcp.example.com
+	Port int    // A/AAAA记录的服务端口
+	SRV  bool   // 是否为SRV记录
+}
+
+var _ route.Watchable = &Table{}
+
+// Table 定期解析DNS记录得到endpoints的路由表, 解析失败时保留上一次成功的结果
+type Table struct {
+	record   Record
+	resolver Resolver
+	timeout  time.Duration
+	done     chan struct{}
+	once     sync.Once
+	notifier route.Notifier
+
+	mu        sync.RWMutex
+	endpoints []endpoint.Endpoint
+}
+
+func NewTable(record Record, interval time.Duration, resolver Resolver) *Table {
+	if record.Net == "" {
+		record.Net = "tcp"
+	}
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	timeout := DefaultTimeout
+	if timeout > interval {
+		timeout = interval
+	}
+	t := &Table{
+		record:   record,
+		resolver: resolver,
+		timeout:  timeout,
+		done:     make(chan struct{}),
+	}
+	t.refresh()
+	go t.refreshing(interval)
+	return t
+}
+
+func (t *Table) Close() error {
+	t.once.Do(func() { close(t.done) })
+	return nil
+}
+
+func (t *Table) ListEndpoints() []endpoint.Endpoint {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.endpoints
+}
+
+func (t *Table) Watch(f route.WatchFunc) (cancel func()) {
+	return t.notifier.Watch(f)
+}
+
+func (t *Table) refreshing(interval time.Duration) {
+	tk := time.NewTicker(interval)
+	defer tk.Stop()
+
+	for {
+		select {
+		case <-tk.C:
+			t.refresh()
+		case <-t.done:
+			return
+		}
+	}
+}
+
+func (t *Table) refresh() {
+	ctx, cancel := context.WithTimeout(context.Background(), t.timeout)
+	defer cancel()
+
+	eps, err := t.resolve(ctx)
+	if err != nil {
+		log.Warnf("resolve %s: %v, keep the last endpoints", t.record.Host, err)
+		return
+	}
+	sort.Slice(eps, func(i, j int) bool {
+		return eps[i].Name < eps[j].Name
+	})
+
+	t.notifier.Update(func() route.Event {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		e := route.Diff(t.endpoints, eps)
+		t.endpoints = eps
+		return e
+	})
+}
+
+func (t *Table) resolve(ctx context.Context) ([]endpoint.Endpoint, error) {
+	if t.record.SRV {
+		return t.resolveSRV(ctx)
+	}
+	return t.resolveHost(ctx)
+}
+
+func (t *Table) resolveHost(ctx context.Context) ([]endpoint.Endpoint, error) {
+	addrs, err := t.resolver.LookupHost(ctx, t.record.Host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) <= 0 {
+		return nil, errors.New("no host records")
+	}
+	port := strconv.Itoa(t.record.Port)
+	eps := make([]endpoint.Endpoint, 0, len(addrs))
+	for _, addr := range addrs {
+		hostport := net.JoinHostPort(addr, port)
+		eps = append(eps, endpoint.Endpoint{Name: hostport, Net: t.record.Net, Addr: hostport})
+	}
+	return eps, nil
+}
+
+// resolveSRV 解析SRV记录, 只使用优先级最高(Priority最小)的记录, 记录的权重占比映射为endpoint的Load
+func (t *Table) resolveSRV(ctx context.Context) ([]endpoint.Endpoint, error) {
+	_, srvs, err := t.resolver.LookupSRV(ctx, "", "", t.record.Host)
+	if err != nil {
+		return nil, err
+	}
+	if len(srvs) <= 0 {
+		return nil, errors.New("no srv records")
+	}
+
+	priority := srvs[0].Priority
+	for _, srv := range srvs {
+		if srv.Priority < priority {
+			priority = srv.Priority
+		}
+	}
+	var total float64
+	for _, srv := range srvs {
+		if srv.Priority == priority {
+			total += float64(srv.Weight)
+		}
+	}
+
+	eps := make([]endpoint.Endpoint, 0, len(srvs))
+	for _, srv := range srvs {
+		if srv.Priority != priority {
+			continue
+		}
+		var load float64
+		if total > 0 {
+			load = float64(srv.Weight) / total
+		}
+		hostport := net.JoinHostPort(strings.TrimSuffix(srv.Target, "."), strconv.Itoa(int(srv.Port)))
+		eps = append(eps, endpoint.Endpoint{Name: hostport, Net: t.record.Net, Addr: hostport, Load: load})
+	}
+	return eps, nil
+}
@@ -0,0 +1,121 @@
+package dnstable
+
+import (
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+)
+
+type TestResolver struct {
+	mu    sync.Mutex
+	hosts []string
+	srvs  []*net.SRV
+	err   error
+}
+
+func (r *TestResolver) Set(hosts []string, srvs []*net.SRV, err error) {
+	r.mu.Lock()
+	r.hosts, r.srvs, r.err = hosts, srvs, err
+	r.mu.Unlock()
+}
+
+func (r *TestResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.hosts, r.err
+}
+
+func (r *TestResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return name, r.srvs, r.err
+}
+
+func WaitEndpoints(tb *Table, n int) ([]endpoint.Endpoint, error) {
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if eps := tb.ListEndpoints(); len(eps) == n {
+			return eps, nil
+		}
+	}
+	return nil, errors.New("timeout")
+}
+
+func TestHostTable(t *testing.T) {
+	r := &TestResolver{hosts: []string{"10.0.0.2", "10.0.0.1"}}
+	tb := NewTable(Record{Host: "arith.example.com", Port: 8000}, 10*time.Millisecond, r)
+	defer tb.Close()
+
+	want := []endpoint.Endpoint{
+		{Name: "10.0.0.1:8000", Net: "tcp", Addr: "10.0.0.1:8000"},
+		{Name: "10.0.0.2:8000", Net: "tcp", Addr: "10.0.0.2:8000"},
+	}
+	if got := tb.ListEndpoints(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("endpoints: got %v, want %v", got, want)
+	}
+
+	// 解析失败时保留上一次的结果
+	r.Set(nil, nil, errors.New("dns failure"))
+	time.Sleep(50 * time.Millisecond)
+	if got := tb.ListEndpoints(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("endpoints: got %v, want %v", got, want)
+	}
+
+	r.Set([]string{"::1"}, nil, nil)
+	eps, err := WaitEndpoints(tb, 1)
+	if err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+	if got, want := eps[0].Addr, "[::1]:8000"; got != want {
+		t.Fatalf("addr: got %v, want %v", got, want)
+	}
+}
+
+func TestSRVTable(t *testing.T) {
+	r := &TestResolver{
+		srvs: []*net.SRV{
+			{Target: "a.example.com.", Port: 8000, Priority: 10, Weight: 60},
+			{Target: "b.example.com.", Port: 8001, Priority: 10, Weight: 20},
+			{Target: "c.example.com.", Port: 8002, Priority: 10, Weight: 20},
+			{Target: "d.example.com.", Port: 8003, Priority: 20, Weight: 100},
+		},
+	}
+	tb := NewTable(Record{Host: "_arith._tcp.example.com", SRV: true}, time.Minute, r)
+	defer tb.Close()
+
+	want := []endpoint.Endpoint{
+		{Name: "a.example.com:8000", Net: "tcp", Addr: "a.example.com:8000", Load: 0.6},
+		{Name: "b.example.com:8001", Net: "tcp", Addr: "b.example.com:8001", Load: 0.2},
+		{Name: "c.example.com:8002", Net: "tcp", Addr: "c.example.com:8002", Load: 0.2},
+	}
+	if got := tb.ListEndpoints(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("endpoints: got %v, want %v", got, want)
+	} else {
+		t.Logf("endpoints: got %v", got)
+	}
+}
+
+func TestTableTimeout(t *testing.T) {
+	tests := []struct {
+		interval time.Duration
+		timeout  time.Duration
+	}{
+		{interval: 0, timeout: DefaultTimeout},
+		{interval: time.Minute, timeout: DefaultTimeout},
+		{interval: time.Second, timeout: time.Second},
+	}
+	for i, tt := range tests {
+		tb := NewTable(Record{Host: "arith.example.com", Port: 8000}, tt.interval, &TestResolver{})
+		if got, want := tb.timeout, tt.timeout; got != want {
+			t.Errorf("%d: timeout: got %v, want %v", i, got, want)
+		}
+		tb.Close()
+		tb.Close()
+	}
+}
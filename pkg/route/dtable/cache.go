@@ -0,0 +1,75 @@
+package dtable
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+)
+
+type cache struct {
+	SavedAt   time.Time
+	Endpoints []endpoint.Endpoint
+}
+
+func cacheFile(dir, namespace, service string) string {
+	return filepath.Join(dir, url.PathEscape(namespace)+"."+url.PathEscape(service)+".json")
+}
+
+// loadCache 加载缓存文件, 文件不存在时返回nil
+func loadCache(file string) (*cache, error) {
+	data, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var c cache
+	if err = json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// saveCache 先写临时文件再重命名, 避免进程异常退出时留下不完整的缓存文件.
+// 每次写入使用独立的临时文件, 多个写者并发保存时不会互相覆盖
+func saveCache(file string, eps []endpoint.Endpoint) error {
+	data, err := json.Marshal(cache{SavedAt: time.Now(), Endpoints: eps})
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(file)
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(dir, filepath.Base(file)+".*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if err = writeTemp(f, data); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err = os.Rename(tmp, file); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+func writeTemp(f *os.File, data []byte) error {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0644); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -2,7 +2,9 @@ package dtable
 
 import (
 	"sync"
+	"time"
 
+	log "github.com/ironzhang/tlog"
 	"github.com/ironzhang/x-pearls/govern"
 	"github.com/ironzhang/zerone/pkg/endpoint"
 	"github.com/ironzhang/zerone/pkg/route"
@@ -10,31 +12,79 @@ import (
 
 var _ route.Table = &Table{}
 
+var _ route.Watchable = &Table{}
+
+// Options 路由表选项
+type Options struct {
+	CacheDir    string // 缓存目录, 非空时将endpoint列表持久化到该目录, 启动时先加载缓存
+	IgnoreEmpty bool   // 忽略注册中心推送的空列表, 避免注册中心异常时路由表被清空
+}
+
 type Table struct {
-	consumer  govern.Consumer
-	mu        sync.RWMutex
-	endpoints []endpoint.Endpoint
+	opts     Options
+	cache    string
+	consumer govern.Consumer
+	notifier route.Notifier
+
+	mu         sync.RWMutex
+	endpoints  []endpoint.Endpoint
+	staleSince time.Time // 数据开始陈旧的时间, 零值表示数据来自注册中心的最新推送
 }
 
 func NewTable(driver govern.Driver, service string) *Table {
-	return new(Table).init(driver, service)
+	return NewTableWithOptions(driver, service, Options{})
 }
 
-func (t *Table) init(driver govern.Driver, service string) *Table {
+func NewTableWithOptions(driver govern.Driver, service string, opts Options) *Table {
+	return new(Table).init(driver, service, opts)
+}
+
+func (t *Table) init(driver govern.Driver, service string, opts Options) *Table {
+	t.opts = opts
+	if opts.CacheDir != "" {
+		t.cache = cacheFile(opts.CacheDir, driver.Namespace(), service)
+		if c, err := loadCache(t.cache); err != nil {
+			log.Warnf("load endpoints cache: file=%s: %v", t.cache, err)
+		} else if c != nil {
+			t.endpoints = c.Endpoints
+			t.staleSince = c.SavedAt
+		}
+	}
 	t.consumer = driver.NewConsumer(service, &endpoint.Endpoint{}, t.refresh)
 	return t
 }
 
 func (t *Table) refresh(goeps []govern.Endpoint) {
+	if len(goeps) == 0 && t.opts.IgnoreEmpty {
+		t.mu.Lock()
+		if t.staleSince.IsZero() {
+			t.staleSince = time.Now()
+		}
+		t.mu.Unlock()
+		return
+	}
+
 	eps := make([]endpoint.Endpoint, 0, len(goeps))
 	for _, goep := range goeps {
 		ep := goep.(*endpoint.Endpoint)
 		eps = append(eps, *ep)
 	}
 
-	t.mu.Lock()
-	t.endpoints = eps
-	t.mu.Unlock()
+	// 在通知锁内写缓存, 保证缓存文件与最后一次更新一致
+	t.notifier.Update(func() route.Event {
+		t.mu.Lock()
+		e := route.Diff(t.endpoints, eps)
+		t.endpoints = eps
+		t.staleSince = time.Time{}
+		t.mu.Unlock()
+
+		if t.cache != "" {
+			if err := saveCache(t.cache, eps); err != nil {
+				log.Warnf("save endpoints cache: file=%s: %v", t.cache, err)
+			}
+		}
+		return e
+	})
 }
 
 func (t *Table) Close() error {
@@ -46,3 +96,17 @@ func (t *Table) ListEndpoints() []endpoint.Endpoint {
 	defer t.mu.RUnlock()
 	return t.endpoints
 }
+
+// StaleAge 返回路由表数据的陈旧时间, 数据来自缓存或忽略了空列表时不为0, 收到注册中心的推送后为0
+func (t *Table) StaleAge() time.Duration {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.staleSince.IsZero() {
+		return 0
+	}
+	return time.Since(t.staleSince)
+}
+
+func (t *Table) Watch(f route.WatchFunc) (cancel func()) {
+	return t.notifier.Watch(f)
+}
@@ -2,12 +2,17 @@ package dtable
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/ironzhang/x-pearls/govern"
 	"github.com/ironzhang/x-pearls/govern/stub"
 	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/govern/memory"
+	"github.com/ironzhang/zerone/pkg/route"
 )
 
 func init() {
@@ -69,3 +74,137 @@ func TestTable(t *testing.T) {
 		}
 	}
 }
+
+func TestTableWatch(t *testing.T) {
+	defer time.Sleep(100 * time.Millisecond)
+
+	ns := "TestTableWatch"
+	sv := "TestService"
+
+	d := OpenTestDriver(ns)
+	defer d.Close()
+
+	tb := NewTable(d, sv)
+	defer tb.Close()
+
+	events := make(chan route.Event, 10)
+	cancel := tb.Watch(func(e route.Event) { events <- e })
+	defer cancel()
+
+	ep := &endpoint.Endpoint{Name: "node0", Net: "tcp", Addr: "localhost:2000"}
+	p := d.NewProvider(sv, 10*time.Second, func() govern.Endpoint { return ep })
+	select {
+	case e := <-events:
+		if len(e.Added) != 1 || e.Added[0].Name != "node0" {
+			t.Fatalf("unexpected event: %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("wait added event timeout")
+	}
+
+	p.Close()
+	select {
+	case e := <-events:
+		if len(e.Removed) != 1 || e.Removed[0].Name != "node0" {
+			t.Fatalf("unexpected event: %v", e)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("wait removed event timeout")
+	}
+}
+
+func TestTableCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtable")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	sv := "TestService"
+	opts := Options{CacheDir: dir, IgnoreEmpty: true}
+	ep := &endpoint.Endpoint{Name: "node0", Net: "tcp", Addr: "localhost:2000"}
+
+	// 从注册中心获取endpoint列表并写入缓存
+	d1 := memory.NewDriver("TestTableCache", memory.Config{})
+	p := d1.NewProvider(sv, 10*time.Second, func() govern.Endpoint { return ep })
+	tb1 := NewTableWithOptions(d1, sv, opts)
+	if _, err = ListEndpoints(tb1, 1); err != nil {
+		t.Fatalf("ListEndpoints: %v", err)
+	}
+	if age := tb1.StaleAge(); age != 0 {
+		t.Errorf("stale age: got %v, want 0", age)
+	}
+	tb1.Close()
+	p.Close()
+	d1.Close()
+
+	// 注册中心没有数据时使用缓存
+	d2 := memory.NewDriver("TestTableCache", memory.Config{})
+	defer d2.Close()
+	tb2 := NewTableWithOptions(d2, sv, opts)
+	defer tb2.Close()
+	time.Sleep(50 * time.Millisecond)
+	if eps := tb2.ListEndpoints(); len(eps) != 1 || !eps[0].Equal(ep) {
+		t.Fatalf("endpoints from cache: got %v, want [%v]", eps, ep)
+	}
+	if age := tb2.StaleAge(); age <= 0 {
+		t.Errorf("stale age: got %v, want > 0", age)
+	}
+
+	// 收到注册中心推送后数据不再陈旧
+	p = d2.NewProvider(sv, 10*time.Second, func() govern.Endpoint { return ep })
+	defer p.Close()
+	for i := 0; i < 100 && tb2.StaleAge() != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if age := tb2.StaleAge(); age != 0 {
+		t.Errorf("stale age: got %v, want 0", age)
+	}
+
+	// 不忽略空列表时清空缓存的数据
+	d3 := memory.NewDriver("TestTableCache", memory.Config{})
+	defer d3.Close()
+	tb3 := NewTableWithOptions(d3, sv, Options{CacheDir: dir})
+	defer tb3.Close()
+	if _, err = ListEndpoints(tb3, 0); err != nil {
+		t.Errorf("ListEndpoints: %v", err)
+	}
+}
+
+func TestSaveCacheConcurrent(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dtable")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := cacheFile(dir, "TestSaveCacheConcurrent", "S")
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			eps := []endpoint.Endpoint{{Name: "node0", Net: "tcp", Addr: "localhost:2000"}}
+			if err := saveCache(file, eps); err != nil {
+				t.Errorf("save cache: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	c, err := loadCache(file)
+	if err != nil {
+		t.Fatalf("load cache: %v", err)
+	}
+	if got, want := len(c.Endpoints), 1; got != want {
+		t.Errorf("endpoints: got %v, want %v", got, want)
+	}
+	// 临时文件均已重命名或删除
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if got, want := len(infos), 1; got != want {
+		t.Errorf("files: got %v, want %v", got, want)
+	}
+}
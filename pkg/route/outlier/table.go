@@ -0,0 +1,231 @@
+package outlier
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
+)
+
+var timeNow = time.Now
+
+// Options 异常endpoint检测选项
+type Options struct {
+	ConsecutiveFailures int           // 连续失败多少次后摘除, 默认为5
+	Interval            time.Duration // 错误率统计周期, 默认为10s
+	MinRequests         int           // 参与错误率统计的最少请求数, 默认为20
+	MinFailureRate      float64       // 摘除时错误率的下限, 默认为0.1
+	FailureRateFactor   float64       // 错误率超过其他endpoint平均错误率的倍数时摘除, 默认为2
+	BaseEjectionTime    time.Duration // 基础摘除时间, 每次摘除时间翻倍, 即基础摘除时间乘以2的(摘除次数-1)次方, 默认为30s
+	MaxEjectionTime     time.Duration // 最长摘除时间, 默认为300s
+	MaxEjectionPercent  int           // 最多摘除endpoint的百分比, 默认为50, 至少保留一个endpoint
+	IsFailure           func(error) bool
+}
+
+func (o *Options) setDefaults() {
+	if o.ConsecutiveFailures <= 0 {
+		o.ConsecutiveFailures = 5
+	}
+	if o.Interval <= 0 {
+		o.Interval = 10 * time.Second
+	}
+	if o.MinRequests <= 0 {
+		o.MinRequests = 20
+	}
+	if o.MinFailureRate <= 0 {
+		o.MinFailureRate = 0.1
+	}
+	if o.FailureRateFactor <= 0 {
+		o.FailureRateFactor = 2
+	}
+	if o.BaseEjectionTime <= 0 {
+		o.BaseEjectionTime = 30 * time.Second
+	}
+	if o.MaxEjectionTime <= 0 {
+		o.MaxEjectionTime = 300 * time.Second
+	}
+	if o.MaxEjectionPercent <= 0 {
+		o.MaxEjectionPercent = 50
+	}
+	if o.IsFailure == nil {
+		o.IsFailure = func(err error) bool { return err != nil }
+	}
+}
+
+type host struct {
+	consecutive  int
+	requests     int
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+}
+
+func (h *host) ejected(now time.Time) bool {
+	return now.Before(h.ejectedUntil)
+}
+
+var _ route.Table = &Table{}
+
+// Table 根据调用结果摘除异常endpoint的路由表
+type Table struct {
+	table route.Table
+	opts  Options
+
+	mu       sync.Mutex
+	hosts    map[string]*host
+	analyzed time.Time
+}
+
+func NewTable(table route.Table, opts Options) *Table {
+	opts.setDefaults()
+	return &Table{
+		table:    table,
+		opts:     opts,
+		hosts:    make(map[string]*host),
+		analyzed: timeNow(),
+	}
+}
+
+func hostKey(net, addr string) string {
+	return net + "://" + addr
+}
+
+func (t *Table) ListEndpoints() []endpoint.Endpoint {
+	eps := t.table.ListEndpoints()
+	now := timeNow()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := make([]endpoint.Endpoint, 0, len(eps))
+	for _, ep := range eps {
+		if h, ok := t.hosts[hostKey(ep.Net, ep.Addr)]; ok && h.ejected(now) {
+			continue
+		}
+		res = append(res, ep)
+	}
+	if len(res) <= 0 {
+		return eps
+	}
+	return res
+}
+
+// Ejected 判断endpoint当前是否被摘除
+func (t *Table) Ejected(net, addr string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.hosts[hostKey(net, addr)]
+	return ok && h.ejected(timeNow())
+}
+
+// Report 上报对endpoint的调用结果
+func (t *Table) Report(net, addr string, err error) {
+	now := timeNow()
+	failure := t.opts.IsFailure(err)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := hostKey(net, addr)
+	h, ok := t.hosts[key]
+	if !ok {
+		h = &host{}
+		t.hosts[key] = h
+	}
+	h.requests++
+	if failure {
+		h.failures++
+		h.consecutive++
+		if h.consecutive >= t.opts.ConsecutiveFailures {
+			t.eject(h, now)
+		}
+	} else {
+		h.consecutive = 0
+	}
+
+	if now.Sub(t.analyzed) >= t.opts.Interval {
+		t.analyze(now)
+	}
+}
+
+// analyze 摘除错误率远高于其他endpoint平均错误率的endpoint, 并开始新的统计周期
+func (t *Table) analyze(now time.Time) {
+	t.analyzed = now
+
+	rates := make(map[string]float64)
+	var sum float64
+	for key, h := range t.hosts {
+		if h.requests >= t.opts.MinRequests && !h.ejected(now) {
+			rate := float64(h.failures) / float64(h.requests)
+			rates[key] = rate
+			sum += rate
+		}
+	}
+	if len(rates) >= 2 {
+		for key, rate := range rates {
+			avg := (sum - rate) / float64(len(rates)-1)
+			if rate >= t.opts.MinFailureRate && rate > avg*t.opts.FailureRateFactor {
+				t.eject(t.hosts[key], now)
+			}
+		}
+	}
+
+	current := make(map[string]bool)
+	for _, ep := range t.table.ListEndpoints() {
+		current[hostKey(ep.Net, ep.Addr)] = true
+	}
+	for key, h := range t.hosts {
+		if !current[key] {
+			delete(t.hosts, key)
+			continue
+		}
+		if !h.ejected(now) && h.failures == 0 && h.ejections > 0 {
+			h.ejections--
+		}
+		h.requests = 0
+		h.failures = 0
+	}
+}
+
+func (t *Table) eject(h *host, now time.Time) {
+	if h.ejected(now) || !t.allowEjection(now) {
+		return
+	}
+	h.ejections++
+	h.consecutive = 0
+	h.ejectedUntil = now.Add(ejectionTime(t.opts.BaseEjectionTime, t.opts.MaxEjectionTime, h.ejections))
+}
+
+// ejectionTime 返回第n次摘除的摘除时间base<<(n-1), 不超过max
+func ejectionTime(base, max time.Duration, n int) time.Duration {
+	d := base
+	for i := 1; i < n; i++ {
+		// 翻倍前检查, 避免移位溢出
+		if d > max/2 {
+			return max
+		}
+		d <<= 1
+	}
+	if d > max {
+		d = max
+	}
+	return d
+}
+
+func (t *Table) allowEjection(now time.Time) bool {
+	eps := t.table.ListEndpoints()
+	ejected := 0
+	for _, ep := range eps {
+		if h, ok := t.hosts[hostKey(ep.Net, ep.Addr)]; ok && h.ejected(now) {
+			ejected++
+		}
+	}
+	max := len(eps) * t.opts.MaxEjectionPercent / 100
+	if max < 1 {
+		max = 1
+	}
+	if max > len(eps)-1 {
+		max = len(eps) - 1
+	}
+	return ejected < max
+}
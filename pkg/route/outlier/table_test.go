@@ -0,0 +1,153 @@
+package outlier
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route/stable"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func (c *clock) Add(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func NewTestClock() *clock {
+	c := &clock{now: time.Now()}
+	timeNow = c.Now
+	return c
+}
+
+func NewTestTable(n int) *stable.Table {
+	addrs := []string{"localhost:10000", "localhost:10001", "localhost:10002", "localhost:10003"}
+	eps := make([]endpoint.Endpoint, 0, n)
+	for i := 0; i < n; i++ {
+		eps = append(eps, endpoint.Endpoint{Name: addrs[i], Net: "tcp", Addr: addrs[i]})
+	}
+	return stable.NewTable(eps)
+}
+
+func ListAddrs(t *Table) []string {
+	var addrs []string
+	for _, ep := range t.ListEndpoints() {
+		addrs = append(addrs, ep.Addr)
+	}
+	return addrs
+}
+
+func TestConsecutiveFailures(t *testing.T) {
+	c := NewTestClock()
+	tb := NewTable(NewTestTable(3), Options{ConsecutiveFailures: 3, BaseEjectionTime: time.Second, MaxEjectionTime: 5 * time.Second, Interval: time.Hour})
+
+	tb.Report("tcp", "localhost:10000", io.EOF)
+	tb.Report("tcp", "localhost:10000", io.EOF)
+	tb.Report("tcp", "localhost:10000", nil)
+	tb.Report("tcp", "localhost:10000", io.EOF)
+	tb.Report("tcp", "localhost:10000", io.EOF)
+	if tb.Ejected("tcp", "localhost:10000") {
+		t.Fatalf("ejected after non consecutive failures")
+	}
+
+	// 摘除时间按指数增长, 不超过MaxEjectionTime
+	durations := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, d := range durations {
+		for j := 0; j < 3; j++ {
+			tb.Report("tcp", "localhost:10000", io.EOF)
+		}
+		if got, want := ListAddrs(tb), []string{"localhost:10001", "localhost:10002"}; !reflect.DeepEqual(got, want) {
+			t.Fatalf("%d: endpoints: got %v, want %v", i, got, want)
+		}
+		c.Add(d - time.Millisecond)
+		if !tb.Ejected("tcp", "localhost:10000") {
+			t.Fatalf("%d: not ejected before %v", i, d)
+		}
+		c.Add(time.Millisecond)
+		if tb.Ejected("tcp", "localhost:10000") {
+			t.Fatalf("%d: ejected after %v", i, d)
+		}
+	}
+}
+
+func TestEjectionTime(t *testing.T) {
+	const max = time.Duration(1<<63 - 1)
+	tests := []struct {
+		base time.Duration
+		max  time.Duration
+		n    int
+		want time.Duration
+	}{
+		{base: 30 * time.Second, max: 300 * time.Second, n: 1, want: 30 * time.Second},
+		{base: 30 * time.Second, max: 300 * time.Second, n: 2, want: 60 * time.Second},
+		{base: 30 * time.Second, max: 300 * time.Second, n: 3, want: 120 * time.Second},
+		{base: 30 * time.Second, max: 300 * time.Second, n: 4, want: 240 * time.Second},
+		{base: 30 * time.Second, max: 300 * time.Second, n: 5, want: 300 * time.Second},
+		{base: 30 * time.Second, max: 300 * time.Second, n: 1000, want: 300 * time.Second},
+		{base: 30 * time.Second, max: max, n: 1000, want: max},
+	}
+	for i, tt := range tests {
+		if got := ejectionTime(tt.base, tt.max, tt.n); got != tt.want {
+			t.Errorf("%d: got %v, want %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestMaxEjectionPercent(t *testing.T) {
+	NewTestClock()
+	tests := []struct {
+		n       int
+		percent int
+		ejected int
+	}{
+		{n: 1, percent: 100, ejected: 0},
+		{n: 2, percent: 10, ejected: 1},
+		{n: 3, percent: 100, ejected: 2},
+		{n: 4, percent: 50, ejected: 2},
+		{n: 4, percent: 80, ejected: 3},
+	}
+	for i, tt := range tests {
+		tb := NewTable(NewTestTable(tt.n), Options{ConsecutiveFailures: 1, MaxEjectionPercent: tt.percent})
+		for _, ep := range tb.table.ListEndpoints() {
+			tb.Report(ep.Net, ep.Addr, io.EOF)
+		}
+		if got, want := len(ListAddrs(tb)), tt.n-tt.ejected; got != want {
+			t.Errorf("%d: endpoints: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestFailureRate(t *testing.T) {
+	c := NewTestClock()
+	tb := NewTable(NewTestTable(4), Options{ConsecutiveFailures: 100, Interval: time.Second, MinRequests: 10})
+
+	report := func(addr string, requests, failures int) {
+		for i := 0; i < requests; i++ {
+			var err error
+			if i < failures {
+				err = io.EOF
+			}
+			tb.Report("tcp", addr, err)
+		}
+	}
+	report("localhost:10000", 20, 10)
+	report("localhost:10001", 20, 1)
+	report("localhost:10002", 20, 1)
+	report("localhost:10003", 5, 5)
+	c.Add(time.Second)
+	tb.Report("tcp", "localhost:10001", nil)
+
+	if got, want := ListAddrs(tb), []string{"localhost:10001", "localhost:10002", "localhost:10003"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("endpoints: got %v, want %v", got, want)
+	} else {
+		t.Logf("endpoints: got %v", got)
+	}
+}
@@ -0,0 +1,189 @@
+package split
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	log "github.com/ironzhang/tlog"
+	"github.com/ironzhang/x-pearls/config"
+	"github.com/ironzhang/x-pearls/govern"
+	"github.com/ironzhang/zerone/pkg/route/stable"
+)
+
+type Rules map[string][]Rule
+
+// LoadRules 从静态路由文件中加载切分规则, 规则定义在对象格式服务的Split字段中:
+//
+//	{"Arith": {"Endpoints": [...], "Split": [{"Name": "canary", "Tags": {"version": "v2"}, "Percent": 10}]}}
+func LoadRules(filename string) (Rules, error) {
+	var rules Rules
+	if err := config.LoadFromFile(filename, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// UnmarshalJSON 从路由文件中解析切分规则, 忽略数组格式的服务
+func (r *Rules) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	rules := make(Rules)
+	for name, raw := range m {
+		if raw = bytes.TrimSpace(raw); len(raw) <= 0 || raw[0] != '{' {
+			continue
+		}
+		var s struct{ Split []Rule }
+		if err := json.Unmarshal(raw, &s); err != nil {
+			return fmt.Errorf("service(%s): %v", name, err)
+		}
+		if err := checkRules(s.Split); err != nil {
+			return fmt.Errorf("service(%s): %v", name, err)
+		}
+		if len(s.Split) > 0 {
+			rules[name] = s.Split
+		}
+	}
+	*r = rules
+	return nil
+}
+
+// FileSource 从静态路由文件加载切分规则, 路由文件重新加载后同步刷新切分规则
+type FileSource struct {
+	watcher *stable.Watcher
+	cancel  func()
+	once    sync.Once
+
+	mu      sync.Mutex
+	rules   Rules
+	routers map[string]*Router
+}
+
+// NewFileSource 从w的路由文件中加载切分规则, 由w驱动热加载
+func NewFileSource(w *stable.Watcher) (*FileSource, error) {
+	rules, err := LoadRules(w.Filename())
+	if err != nil {
+		return nil, err
+	}
+
+	s := &FileSource{
+		watcher: w,
+		rules:   rules,
+		routers: make(map[string]*Router),
+	}
+	s.cancel = w.OnReload(func() {
+		if err := s.Reload(); err != nil {
+			log.Warnf("reload split rules from %s: %v", w.Filename(), err)
+		}
+	})
+	return s, nil
+}
+
+func (s *FileSource) Router(service string) *Router {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.routers[service]
+	if !ok {
+		r = NewRouter()
+		r.SetRules(s.rules[service])
+		s.routers[service] = r
+	}
+	return r
+}
+
+// HasRules 判断路由文件中是否配置了服务的切分规则
+func (s *FileSource) HasRules(service string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.rules[service]) > 0
+}
+
+func (s *FileSource) Close() error {
+	s.once.Do(s.cancel)
+	return nil
+}
+
+// Reload 重新加载切分规则, 失败时保留原有的规则
+func (s *FileSource) Reload() error {
+	rules, err := LoadRules(s.watcher.Filename())
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = rules
+	for service, r := range s.routers {
+		r.SetRules(rules[service])
+	}
+	return nil
+}
+
+// ServiceName 返回切分规则在服务治理中的服务名
+func ServiceName(service string) string {
+	return service + ".split"
+}
+
+// Publish 通过服务治理发布service的切分规则
+func Publish(driver govern.Driver, service string, interval time.Duration, rule Rule) govern.Provider {
+	return driver.NewProvider(ServiceName(service), interval, func() govern.Endpoint {
+		return &rule
+	})
+}
+
+// DriverSource 从服务治理中订阅切分规则
+type DriverSource struct {
+	driver govern.Driver
+
+	mu        sync.Mutex
+	routers   map[string]*Router
+	consumers []govern.Consumer
+}
+
+func NewDriverSource(driver govern.Driver) *DriverSource {
+	return &DriverSource{
+		driver:  driver,
+		routers: make(map[string]*Router),
+	}
+}
+
+func (s *DriverSource) Router(service string) *Router {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.routers[service]
+	if !ok {
+		r = NewRouter()
+		s.routers[service] = r
+		s.consumers = append(s.consumers, s.driver.NewConsumer(ServiceName(service), &Rule{}, func(goeps []govern.Endpoint) {
+			rules := make([]Rule, 0, len(goeps))
+			for _, goep := range goeps {
+				rules = append(rules, *goep.(*Rule))
+			}
+			sort.Slice(rules, func(i, j int) bool {
+				return rules[i].Name < rules[j].Name
+			})
+			if err := r.SetRules(rules); err != nil {
+				log.Warnf("refresh service(%s) split rules: %v", service, err)
+			}
+		}))
+	}
+	return r
+}
+
+func (s *DriverSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.consumers {
+		c.Close()
+	}
+	s.consumers = nil
+	return nil
+}
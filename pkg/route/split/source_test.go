@@ -0,0 +1,124 @@
+package split
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/x-pearls/govern"
+	"github.com/ironzhang/x-pearls/govern/stub"
+	"github.com/ironzhang/zerone/pkg/route/stable"
+)
+
+const routeFile = `{
+	"Arith": {
+		"Endpoints": [{"Name": "S1", "Net": "tcp", "Addr": "localhost:8000", "Tags": {"version": "v2"}}],
+		"Split": [{"Name": "canary", "Tags": {"version": "v2"}, "Percent": 10}]
+	},
+	"Echo": [{"Name": "S1", "Net": "tcp", "Addr": "localhost:8000"}]
+}`
+
+func WaitRules(r *Router, n int) ([]Rule, error) {
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if rules := r.Rules(); len(rules) == n {
+			return rules, nil
+		}
+	}
+	return nil, errors.New("timeout")
+}
+
+func TestLoadRules(t *testing.T) {
+	filename := "rules.json"
+	if err := ioutil.WriteFile(filename, []byte(routeFile), 0666); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	defer os.Remove(filename)
+
+	rules, err := LoadRules(filename)
+	if err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+	want := Rules{
+		"Arith": []Rule{{Name: "canary", Tags: map[string]string{"version": "v2"}, Percent: 10}},
+	}
+	if got := rules; !reflect.DeepEqual(got, want) {
+		t.Fatalf("rules: got %v, want %v", got, want)
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	filename := "source.json"
+	if err := ioutil.WriteFile(filename, []byte(routeFile), 0666); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	defer os.Remove(filename)
+
+	w, err := stable.NewWatcher(filename, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+	defer w.Close()
+
+	s, err := NewFileSource(w)
+	if err != nil {
+		t.Fatalf("new file source: %v", err)
+	}
+	defer s.Close()
+
+	r := s.Router("Echo")
+	if got, want := len(r.Rules()), 0; got != want {
+		t.Fatalf("rules: got %d, want %d", got, want)
+	}
+
+	// 非法规则不生效
+	invalid := `{"Echo": {"Endpoints": [{"Name": "S1", "Net": "tcp", "Addr": "localhost:8000"}], "Split": [{"Name": "canary", "Tags": {"version": "v2"}, "Percent": 200}]}}`
+	if err = ioutil.WriteFile(filename, []byte(invalid), 0666); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err = WaitRules(r, 1); err == nil {
+		t.Fatalf("invalid rules loaded")
+	}
+
+	valid := `{"Echo": {"Endpoints": [{"Name": "S1", "Net": "tcp", "Addr": "localhost:8000"}], "Split": [{"Name": "canary", "Tags": {"version": "v2"}, "Percent": 20}]}}`
+	if err = ioutil.WriteFile(filename, []byte(valid), 0666); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	rules, err := WaitRules(r, 1)
+	if err != nil {
+		t.Fatalf("wait rules: %v", err)
+	}
+	if got, want := rules[0].Percent, 20.0; got != want {
+		t.Fatalf("percent: got %v, want %v", got, want)
+	}
+}
+
+func TestDriverSource(t *testing.T) {
+	d, err := govern.Open(stub.DriverName, "TestDriverSource", nil)
+	if err != nil {
+		t.Fatalf("open driver: %v", err)
+	}
+	defer d.Close()
+
+	s := NewDriverSource(d)
+	defer s.Close()
+	r := s.Router("Arith")
+
+	rule := Rule{Name: "canary", Tags: map[string]string{"version": "v2"}, Percent: 10}
+	p := Publish(d, "Arith", time.Second, rule)
+	rules, err := WaitRules(r, 1)
+	if err != nil {
+		t.Fatalf("wait rules: %v", err)
+	}
+	if got, want := rules[0], rule; !got.Equal(&want) {
+		t.Fatalf("rule: got %v, want %v", got, want)
+	}
+
+	p.Close()
+	if _, err = WaitRules(r, 0); err != nil {
+		t.Fatalf("wait rules: %v", err)
+	}
+}
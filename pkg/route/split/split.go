@@ -0,0 +1,173 @@
+package split
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"math/rand"
+	"sync"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
+)
+
+// DefaultSubset 未命中任何规则的流量所路由到的子集
+const DefaultSubset = "default"
+
+// Rule 流量切分规则, 将Percent百分比的流量路由到包含Tags标签的endpoint子集上
+type Rule struct {
+	Name    string            // 规则名称, 即子集名称
+	Tags    map[string]string // 子集endpoint需包含的标签
+	Percent float64           // 流量百分比, 取值范围[0, 100]
+}
+
+func (p *Rule) Node() string {
+	return p.Name
+}
+
+func (p *Rule) String() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+func (p *Rule) Equal(a interface{}) bool {
+	r := a.(*Rule)
+	if p.Name != r.Name || p.Percent != r.Percent || len(p.Tags) != len(r.Tags) {
+		return false
+	}
+	for k, v := range p.Tags {
+		if rv, ok := r.Tags[k]; !ok || rv != v {
+			return false
+		}
+	}
+	return true
+}
+
+func checkRules(rules []Rule) error {
+	var total float64
+	names := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		if r.Name == "" || r.Name == DefaultSubset {
+			return fmt.Errorf("invalid rule name %q", r.Name)
+		}
+		if names[r.Name] {
+			return fmt.Errorf("rule(%s) duplicate", r.Name)
+		}
+		names[r.Name] = true
+		if len(r.Tags) <= 0 {
+			return fmt.Errorf("rule(%s) tags is empty", r.Name)
+		}
+		if r.Percent < 0 || r.Percent > 100 {
+			return fmt.Errorf("rule(%s) percent %v out of range", r.Name, r.Percent)
+		}
+		total += r.Percent
+	}
+	if total > 100 {
+		return fmt.Errorf("total percent %v out of range", total)
+	}
+	return nil
+}
+
+// Router 按规则为每次调用选择endpoint子集
+type Router struct {
+	mu    sync.RWMutex
+	rules []Rule
+}
+
+func NewRouter() *Router {
+	return &Router{}
+}
+
+func (r *Router) SetRules(rules []Rule) error {
+	if err := checkRules(rules); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.rules = rules
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Router) Rules() []Rule {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.rules
+}
+
+// Pick 选择子集, key不为空时同一个key总是选择同一个子集, 没有规则时返回空字符串
+func (r *Router) Pick(key []byte) string {
+	rules := r.Rules()
+	if len(rules) <= 0 {
+		return ""
+	}
+
+	var point float64
+	if len(key) > 0 {
+		point = float64(crc32.ChecksumIEEE(key) % 10000)
+	} else {
+		point = float64(rand.Intn(10000))
+	}
+	var sum float64
+	for _, rule := range rules {
+		sum += rule.Percent * 100
+		if point < sum {
+			return rule.Name
+		}
+	}
+	return DefaultSubset
+}
+
+var _ route.Table = &Table{}
+
+// Table 路由表的子集视图, 子集为空时返回全部endpoint
+type Table struct {
+	parent route.Table
+	router *Router
+	subset string
+}
+
+func NewTable(parent route.Table, router *Router, subset string) *Table {
+	return &Table{parent: parent, router: router, subset: subset}
+}
+
+func (t *Table) ListEndpoints() []endpoint.Endpoint {
+	eps := t.parent.ListEndpoints()
+	if subset := filter(eps, t.router.Rules(), t.subset); len(subset) > 0 {
+		return subset
+	}
+	return eps
+}
+
+func filter(eps []endpoint.Endpoint, rules []Rule, subset string) []endpoint.Endpoint {
+	if subset == DefaultSubset {
+		var res []endpoint.Endpoint
+		for _, ep := range eps {
+			if !matchAny(ep, rules) {
+				res = append(res, ep)
+			}
+		}
+		return res
+	}
+	for _, rule := range rules {
+		if rule.Name != subset {
+			continue
+		}
+		var res []endpoint.Endpoint
+		for _, ep := range eps {
+			if ep.Match(rule.Tags) {
+				res = append(res, ep)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+func matchAny(ep endpoint.Endpoint, rules []Rule) bool {
+	for _, rule := range rules {
+		if ep.Match(rule.Tags) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package split
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route/stable"
+)
+
+func TestCheckRules(t *testing.T) {
+	tests := []struct {
+		rules []Rule
+		ok    bool
+	}{
+		{rules: nil, ok: true},
+		{rules: []Rule{{Name: "canary", Tags: map[string]string{"version": "v2"}, Percent: 10}}, ok: true},
+		{rules: []Rule{{Name: "", Tags: map[string]string{"version": "v2"}, Percent: 10}}, ok: false},
+		{rules: []Rule{{Name: DefaultSubset, Tags: map[string]string{"version": "v2"}, Percent: 10}}, ok: false},
+		{rules: []Rule{{Name: "canary", Percent: 10}}, ok: false},
+		{rules: []Rule{{Name: "canary", Tags: map[string]string{"version": "v2"}, Percent: 101}}, ok: false},
+		{
+			rules: []Rule{
+				{Name: "v2", Tags: map[string]string{"version": "v2"}, Percent: 60},
+				{Name: "v3", Tags: map[string]string{"version": "v3"}, Percent: 50},
+			},
+			ok: false,
+		},
+		{
+			rules: []Rule{
+				{Name: "v2", Tags: map[string]string{"version": "v2"}, Percent: 10},
+				{Name: "v2", Tags: map[string]string{"version": "v3"}, Percent: 10},
+			},
+			ok: false,
+		},
+	}
+	for i, tt := range tests {
+		if got, want := checkRules(tt.rules) == nil, tt.ok; got != want {
+			t.Errorf("%d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestRouterPick(t *testing.T) {
+	r := NewRouter()
+	if got, want := r.Pick([]byte("user")), ""; got != want {
+		t.Fatalf("no rules: got %q, want %q", got, want)
+	}
+
+	rules := []Rule{{Name: "canary", Tags: map[string]string{"version": "v2"}, Percent: 20}}
+	if err := r.SetRules(rules); err != nil {
+		t.Fatalf("set rules: %v", err)
+	}
+
+	const n = 10000
+	counts := make(map[string]int)
+	for i := 0; i < n; i++ {
+		key := []byte(fmt.Sprintf("user-%d", i))
+		subset := r.Pick(key)
+		counts[subset]++
+		for j := 0; j < 3; j++ {
+			if got := r.Pick(key); got != subset {
+				t.Fatalf("%s: not sticky: %s != %s", key, got, subset)
+			}
+		}
+	}
+	if c := counts["canary"]; c < n*15/100 || c > n*25/100 {
+		t.Errorf("canary count %d out of range", c)
+	}
+	if got, want := counts["canary"]+counts[DefaultSubset], n; got != want {
+		t.Errorf("total: got %d, want %d", got, want)
+	}
+	t.Logf("counts: %v", counts)
+}
+
+func TestTable(t *testing.T) {
+	v1 := map[string]string{"version": "v1"}
+	v2 := map[string]string{"version": "v2"}
+	v3 := map[string]string{"version": "v3"}
+	tb := stable.NewTable([]endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:10000", Tags: endpoint.NewTags(v1)},
+		{Name: "1", Net: "tcp", Addr: "localhost:10001", Tags: endpoint.NewTags(v1)},
+		{Name: "2", Net: "tcp", Addr: "localhost:10002", Tags: endpoint.NewTags(v2)},
+	})
+	r := NewRouter()
+	r.SetRules([]Rule{
+		{Name: "v2", Tags: v2, Percent: 10},
+		{Name: "v3", Tags: v3, Percent: 10},
+	})
+
+	tests := []struct {
+		subset string
+		names  []string
+	}{
+		{subset: DefaultSubset, names: []string{"0", "1"}},
+		{subset: "v2", names: []string{"2"}},
+		{subset: "v3", names: []string{"0", "1", "2"}},
+		{subset: "unknown", names: []string{"0", "1", "2"}},
+	}
+	for i, tt := range tests {
+		var names []string
+		for _, ep := range NewTable(tb, r, tt.subset).ListEndpoints() {
+			names = append(names, ep.Name)
+		}
+		if got, want := names, tt.names; !reflect.DeepEqual(got, want) {
+			t.Errorf("%d: %s: got %v, want %v", i, tt.subset, got, want)
+		} else {
+			t.Logf("%d: %s: got %v", i, tt.subset, got)
+		}
+	}
+}
@@ -1,33 +1,93 @@
 package stable
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"sort"
+	"sync"
 
 	"github.com/ironzhang/x-pearls/config"
 	"github.com/ironzhang/zerone/pkg/endpoint"
 	"github.com/ironzhang/zerone/pkg/route"
 )
 
-var _ route.Table = &Table{}
+var _ route.Watchable = &Table{}
 
 type Table struct {
+	notifier  route.Notifier
+	mu        sync.RWMutex
 	endpoints []endpoint.Endpoint
 }
 
 func NewTable(endpoints []endpoint.Endpoint) *Table {
+	sortEndpoints(endpoints)
+	return &Table{endpoints: endpoints}
+}
+
+func sortEndpoints(endpoints []endpoint.Endpoint) {
 	sort.Slice(endpoints, func(i, j int) bool {
 		return endpoints[i].Name < endpoints[j].Name
 	})
-	return &Table{endpoints: endpoints}
 }
 
 func (t *Table) ListEndpoints() []endpoint.Endpoint {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
 	return t.endpoints
 }
 
+func (t *Table) setEndpoints(endpoints []endpoint.Endpoint) {
+	eps := make([]endpoint.Endpoint, len(endpoints))
+	copy(eps, endpoints)
+	sortEndpoints(eps)
+
+	t.notifier.Update(func() route.Event {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		e := route.Diff(t.endpoints, eps)
+		t.endpoints = eps
+		return e
+	})
+}
+
+func (t *Table) Watch(f route.WatchFunc) (cancel func()) {
+	return t.notifier.Watch(f)
+}
+
 type Tables map[string][]endpoint.Endpoint
 
+// service 路由文件中服务的对象格式, 如: {"Endpoints": [...], "Split": [...]}
+type service struct {
+	Endpoints []endpoint.Endpoint
+}
+
+// UnmarshalJSON 兼容服务的数组格式和对象格式
+func (t *Tables) UnmarshalJSON(data []byte) error {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	tables := make(Tables, len(m))
+	for name, raw := range m {
+		if raw = bytes.TrimSpace(raw); len(raw) > 0 && raw[0] == '{' {
+			var s service
+			if err := json.Unmarshal(raw, &s); err != nil {
+				return fmt.Errorf("service(%s): %v", name, err)
+			}
+			tables[name] = s.Endpoints
+		} else {
+			var eps []endpoint.Endpoint
+			if err := json.Unmarshal(raw, &eps); err != nil {
+				return fmt.Errorf("service(%s): %v", name, err)
+			}
+			tables[name] = eps
+		}
+	}
+	*t = tables
+	return nil
+}
+
 func LoadTables(filename string) (Tables, error) {
 	var tables Tables
 	if err := config.LoadFromFile(filename, &tables); err != nil {
@@ -36,6 +96,26 @@ func LoadTables(filename string) (Tables, error) {
 	return tables, nil
 }
 
+// Validate 检查路由表: 每个服务至少有一个endpoint, endpoint的Name, Net, Addr不能为空且Name不能重复
+func (t Tables) Validate() error {
+	for service, endpoints := range t {
+		if len(endpoints) <= 0 {
+			return fmt.Errorf("service(%s) endpoints is empty", service)
+		}
+		names := make(map[string]bool, len(endpoints))
+		for _, ep := range endpoints {
+			if ep.Name == "" || ep.Net == "" || ep.Addr == "" {
+				return fmt.Errorf("service(%s) endpoint %s is invalid", service, ep.String())
+			}
+			if names[ep.Name] {
+				return fmt.Errorf("service(%s) endpoint(%s) duplicate", service, ep.Name)
+			}
+			names[ep.Name] = true
+		}
+	}
+	return nil
+}
+
 func (t Tables) Lookup(service string) (*Table, error) {
 	endpoints, ok := t[service]
 	if !ok {
@@ -1,12 +1,17 @@
 package stable
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ironzhang/x-pearls/config"
 	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
 )
 
 func TestTable(t *testing.T) {
@@ -16,26 +21,26 @@ func TestTable(t *testing.T) {
 	}{
 		{
 			ins: []endpoint.Endpoint{
-				{"0", "tcp", "localhost:10000", 0.0},
-				{"1", "tcp", "localhost:10001", 0.11},
-				{"2", "tcp", "localhost:10002", 0.222},
+				{Name: "0", Net: "tcp", Addr: "localhost:10000", Load: 0.0},
+				{Name: "1", Net: "tcp", Addr: "localhost:10001", Load: 0.11},
+				{Name: "2", Net: "tcp", Addr: "localhost:10002", Load: 0.222},
 			},
 			outs: []endpoint.Endpoint{
-				{"0", "tcp", "localhost:10000", 0.0},
-				{"1", "tcp", "localhost:10001", 0.11},
-				{"2", "tcp", "localhost:10002", 0.222},
+				{Name: "0", Net: "tcp", Addr: "localhost:10000", Load: 0.0},
+				{Name: "1", Net: "tcp", Addr: "localhost:10001", Load: 0.11},
+				{Name: "2", Net: "tcp", Addr: "localhost:10002", Load: 0.222},
 			},
 		},
 		{
 			ins: []endpoint.Endpoint{
-				{"1", "udp", "localhost:10001", 0.11},
-				{"0", "udp", "localhost:10000", 0.0},
-				{"2", "udp", "localhost:10002", 0.222},
+				{Name: "1", Net: "udp", Addr: "localhost:10001", Load: 0.11},
+				{Name: "0", Net: "udp", Addr: "localhost:10000", Load: 0.0},
+				{Name: "2", Net: "udp", Addr: "localhost:10002", Load: 0.222},
 			},
 			outs: []endpoint.Endpoint{
-				{"0", "udp", "localhost:10000", 0.0},
-				{"1", "udp", "localhost:10001", 0.11},
-				{"2", "udp", "localhost:10002", 0.222},
+				{Name: "0", Net: "udp", Addr: "localhost:10000", Load: 0.0},
+				{Name: "1", Net: "udp", Addr: "localhost:10001", Load: 0.11},
+				{Name: "2", Net: "udp", Addr: "localhost:10002", Load: 0.222},
 			},
 		},
 	}
@@ -53,14 +58,14 @@ func TestLoadTables(t *testing.T) {
 	filename := "example.json"
 	wtables := Tables{
 		"account": []endpoint.Endpoint{
-			{"0", "tcp", "localhost:10000", 0.0},
-			{"1", "tcp", "localhost:10001", 0.11},
-			{"2", "tcp", "localhost:10002", 0.222},
+			{Name: "0", Net: "tcp", Addr: "localhost:10000", Load: 0.0},
+			{Name: "1", Net: "tcp", Addr: "localhost:10001", Load: 0.11},
+			{Name: "2", Net: "tcp", Addr: "localhost:10002", Load: 0.222},
 		},
 		"logger": []endpoint.Endpoint{
-			{"0", "udp", "localhost:10000", 0.0},
-			{"1", "udp", "localhost:10001", 0.11},
-			{"2", "udp", "localhost:10002", 0.222},
+			{Name: "0", Net: "udp", Addr: "localhost:10000", Load: 0.0},
+			{Name: "1", Net: "udp", Addr: "localhost:10001", Load: 0.11},
+			{Name: "2", Net: "udp", Addr: "localhost:10002", Load: 0.222},
 		},
 	}
 	if err := config.WriteToFile(filename, wtables); err != nil {
@@ -82,14 +87,14 @@ func TestLoadTables(t *testing.T) {
 func TestTablesLookup(t *testing.T) {
 	tables := Tables{
 		"account": []endpoint.Endpoint{
-			{"0", "tcp", "localhost:10000", 0.0},
-			{"1", "tcp", "localhost:10001", 0.11},
-			{"2", "tcp", "localhost:10002", 0.222},
+			{Name: "0", Net: "tcp", Addr: "localhost:10000", Load: 0.0},
+			{Name: "1", Net: "tcp", Addr: "localhost:10001", Load: 0.11},
+			{Name: "2", Net: "tcp", Addr: "localhost:10002", Load: 0.222},
 		},
 		"logger": []endpoint.Endpoint{
-			{"0", "udp", "localhost:10000", 0.0},
-			{"1", "udp", "localhost:10001", 0.11},
-			{"2", "udp", "localhost:10002", 0.222},
+			{Name: "0", Net: "udp", Addr: "localhost:10000", Load: 0.0},
+			{Name: "1", Net: "udp", Addr: "localhost:10001", Load: 0.11},
+			{Name: "2", Net: "udp", Addr: "localhost:10002", Load: 0.222},
 		},
 	}
 
@@ -105,3 +110,78 @@ func TestTablesLookup(t *testing.T) {
 		}
 	}
 }
+
+func TestLoadTablesObjectFormat(t *testing.T) {
+	filename := "object.json"
+	data := `{
+	"account": {
+		"Endpoints": [{"Name": "0", "Net": "tcp", "Addr": "localhost:10000", "Tags": {"version": "v2"}}],
+		"Split": [{"Name": "canary", "Tags": {"version": "v2"}, "Percent": 10}]
+	},
+	"logger": [{"Name": "0", "Net": "udp", "Addr": "localhost:10000"}]
+}`
+	if err := ioutil.WriteFile(filename, []byte(data), 0666); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	defer os.Remove(filename)
+
+	rtables, err := LoadTables(filename)
+	if err != nil {
+		t.Fatalf("load table: %v", err)
+	}
+	wtables := Tables{
+		"account": []endpoint.Endpoint{
+			{Name: "0", Net: "tcp", Addr: "localhost:10000", Tags: endpoint.NewTags(map[string]string{"version": "v2"})},
+		},
+		"logger": []endpoint.Endpoint{
+			{Name: "0", Net: "udp", Addr: "localhost:10000"},
+		},
+	}
+	if got, want := rtables, wtables; !reflect.DeepEqual(got, want) {
+		t.Fatalf("tables: got %v, want %v", got, want)
+	} else {
+		t.Logf("tables: got %v", got)
+	}
+}
+
+func TestTableConcurrentUpdate(t *testing.T) {
+	tb := NewTable(nil)
+
+	// 订阅者按事件维护endpoint集合, 事件乱序时集合与路由表不一致.
+	// 处理事件较慢, 使并发的更新在通知时排队
+	names := make(map[string]bool)
+	cancel := tb.Watch(func(e route.Event) {
+		time.Sleep(100 * time.Microsecond)
+		for _, ep := range e.Added {
+			names[ep.Name] = true
+		}
+		for _, ep := range e.Removed {
+			delete(names, ep.Name)
+		}
+	})
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				eps := []endpoint.Endpoint{{Name: fmt.Sprint(i), Net: "tcp", Addr: "localhost:10000"}}
+				if j%2 == 0 {
+					eps = append(eps, endpoint.Endpoint{Name: fmt.Sprint(i, "-", j), Net: "tcp", Addr: "localhost:10000"})
+				}
+				tb.setEndpoints(eps)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	want := make(map[string]bool)
+	for _, ep := range tb.ListEndpoints() {
+		want[ep.Name] = true
+	}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("names: got %v, want %v", names, want)
+	}
+}
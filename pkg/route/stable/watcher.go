@@ -0,0 +1,171 @@
+package stable
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	log "github.com/ironzhang/tlog"
+)
+
+// Watcher 从路由文件加载路由表, 并在文件变更或收到指定信号时重新加载,
+// 重新加载后Lookup返回的Table会原子地切换到新的endpoints, 重新加载时非法的路由文件会被忽略
+type Watcher struct {
+	filename string
+	modTime  time.Time
+	signals  chan os.Signal
+	done     chan struct{}
+	once     sync.Once
+
+	mu       sync.Mutex
+	tables   Tables
+	services map[string]*Table
+	token    int
+	reloads  map[int]func()
+}
+
+// NewWatcher 创建路由文件监视器, interval大于0时定期检查文件变更, 收到signals中的信号时也会重新加载
+func NewWatcher(filename string, interval time.Duration, signals ...os.Signal) (*Watcher, error) {
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+	tables, err := LoadTables(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		filename: filename,
+		modTime:  fi.ModTime(),
+		done:     make(chan struct{}),
+		tables:   tables,
+		services: make(map[string]*Table),
+		reloads:  make(map[int]func()),
+	}
+	if len(signals) > 0 {
+		w.signals = make(chan os.Signal, 1)
+		signal.Notify(w.signals, signals...)
+	}
+	if interval > 0 || w.signals != nil {
+		go w.watching(interval)
+	}
+	return w, nil
+}
+
+func (w *Watcher) Lookup(service string) (*Table, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if t, ok := w.services[service]; ok {
+		return t, nil
+	}
+	t, err := w.tables.Lookup(service)
+	if err != nil {
+		return nil, err
+	}
+	w.services[service] = t
+	return t, nil
+}
+
+// Filename 返回路由文件名
+func (w *Watcher) Filename() string {
+	return w.filename
+}
+
+// OnReload 注册路由文件重新加载成功后的回调函数
+func (w *Watcher) OnReload(f func()) (cancel func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.token++
+	token := w.token
+	w.reloads[token] = f
+	return func() {
+		w.mu.Lock()
+		delete(w.reloads, token)
+		w.mu.Unlock()
+	}
+}
+
+// Reload 重新加载路由文件, 失败时保留原有的endpoints,
+// 只在重新加载时检查路由表, 以兼容初始加载时已接受的路由文件
+func (w *Watcher) Reload() error {
+	tables, err := LoadTables(w.filename)
+	if err != nil {
+		return err
+	}
+	if err = tables.Validate(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	for service := range w.services {
+		if _, ok := tables[service]; !ok {
+			w.mu.Unlock()
+			return fmt.Errorf("service(%s) not found", service)
+		}
+	}
+	w.tables = tables
+	for service, t := range w.services {
+		t.setEndpoints(tables[service])
+	}
+	reloads := make([]func(), 0, len(w.reloads))
+	for _, f := range w.reloads {
+		reloads = append(reloads, f)
+	}
+	w.mu.Unlock()
+
+	for _, f := range reloads {
+		f()
+	}
+	return nil
+}
+
+func (w *Watcher) Close() error {
+	w.once.Do(func() {
+		if w.signals != nil {
+			signal.Stop(w.signals)
+		}
+		close(w.done)
+	})
+	return nil
+}
+
+func (w *Watcher) watching(interval time.Duration) {
+	var tick <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		tick = t.C
+	}
+
+	for {
+		select {
+		case <-tick:
+			fi, err := os.Stat(w.filename)
+			if err != nil {
+				log.Warnf("stat %s: %v", w.filename, err)
+				continue
+			}
+			if fi.ModTime().Equal(w.modTime) {
+				continue
+			}
+			w.modTime = fi.ModTime()
+			w.reload()
+		case sig := <-w.signals:
+			log.Infof("reload %s on signal %v", w.filename, sig)
+			w.reload()
+		case <-w.done:
+			return
+		}
+	}
+}
+
+func (w *Watcher) reload() {
+	if err := w.Reload(); err != nil {
+		log.Warnf("reload route tables from %s: %v, keep the old endpoints", w.filename, err)
+	}
+}
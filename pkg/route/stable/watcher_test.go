@@ -0,0 +1,206 @@
+package stable
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/x-pearls/config"
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
+)
+
+func WaitEndpoints(tb *Table, addr string) error {
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if eps := tb.ListEndpoints(); len(eps) > 0 && eps[0].Addr == addr {
+			return nil
+		}
+	}
+	return errors.New("timeout")
+}
+
+func TestTablesValidate(t *testing.T) {
+	tests := []struct {
+		tables Tables
+		ok     bool
+	}{
+		{
+			tables: Tables{"account": {{Name: "0", Net: "tcp", Addr: "localhost:10000"}}},
+			ok:     true,
+		},
+		{
+			tables: Tables{"account": {}},
+			ok:     false,
+		},
+		{
+			tables: Tables{"account": {{Name: "0", Net: "tcp"}}},
+			ok:     false,
+		},
+		{
+			tables: Tables{"account": {{Name: "0", Net: "tcp", Addr: "localhost:10000"}, {Name: "0", Net: "tcp", Addr: "localhost:10001"}}},
+			ok:     false,
+		},
+	}
+	for i, tt := range tests {
+		if got, want := tt.tables.Validate() == nil, tt.ok; got != want {
+			t.Errorf("%d: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	filename := "watcher.json"
+	write := func(tables Tables) {
+		if err := config.WriteToFile(filename, tables); err != nil {
+			t.Fatalf("write to file: %v", err)
+		}
+	}
+	write(Tables{"account": {{Name: "0", Net: "tcp", Addr: "localhost:10000"}}})
+	defer os.Remove(filename)
+
+	w, err := NewWatcher(filename, 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+	defer w.Close()
+
+	tb, err := w.Lookup("account")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	reloaded := make(chan struct{}, 10)
+	w.OnReload(func() { reloaded <- struct{}{} })
+
+	write(Tables{"account": {{Name: "0", Net: "tcp", Addr: "localhost:10001"}}})
+	if err = WaitEndpoints(tb, "localhost:10001"); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+	<-reloaded
+
+	// 非法的路由文件不生效
+	write(Tables{"account": {}, "logger": []endpoint.Endpoint{{Name: "0", Net: "udp", Addr: "localhost:10000"}}})
+	write(Tables{"logger": []endpoint.Endpoint{{Name: "0", Net: "udp", Addr: "localhost:10000"}}})
+	time.Sleep(50 * time.Millisecond)
+	if got, want := tb.ListEndpoints()[0].Addr, "localhost:10001"; got != want {
+		t.Fatalf("addr: got %v, want %v", got, want)
+	}
+}
+
+func TestNewWatcherUnchecked(t *testing.T) {
+	filename := "unchecked.json"
+	tables := Tables{
+		"account": {{Name: "0", Net: "tcp", Addr: "localhost:10000"}, {Name: "0", Net: "tcp", Addr: "localhost:10001"}},
+		"logger":  {},
+	}
+	if err := config.WriteToFile(filename, tables); err != nil {
+		t.Fatalf("write to file: %v", err)
+	}
+	defer os.Remove(filename)
+
+	// 初始加载不检查路由表, 与LoadTables保持一致
+	w, err := NewWatcher(filename, 0)
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+	defer w.Close()
+
+	if _, err = w.Lookup("account"); err != nil {
+		t.Errorf("lookup account: %v", err)
+	}
+	if _, err = w.Lookup("logger"); err == nil {
+		t.Errorf("lookup logger: expect an error")
+	}
+}
+
+// countLoader 记录通过config.Default加载的次数
+type countLoader struct {
+	config.LoadWriter
+	loads int
+}
+
+func (l *countLoader) LoadFromFile(filename string, cfg interface{}) error {
+	l.loads++
+	return l.LoadWriter.LoadFromFile(filename, cfg)
+}
+
+func TestWatcherConfigDefault(t *testing.T) {
+	loader := &countLoader{LoadWriter: config.Default}
+	config.Default = loader
+	defer func() { config.Default = loader.LoadWriter }()
+
+	filename := "default.json"
+	if err := config.WriteToFile(filename, Tables{"account": {{Name: "0", Net: "tcp", Addr: "localhost:10000"}}}); err != nil {
+		t.Fatalf("write to file: %v", err)
+	}
+	defer os.Remove(filename)
+
+	// 初始加载和重新加载都使用config.Default
+	w, err := NewWatcher(filename, 0)
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+	defer w.Close()
+	if err = w.Reload(); err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if got, want := loader.loads, 2; got != want {
+		t.Errorf("loads: got %v, want %v", got, want)
+	}
+}
+
+func TestWatcherSignal(t *testing.T) {
+	filename := "signal.json"
+	tables := Tables{"account": {{Name: "0", Net: "tcp", Addr: "localhost:10000"}}}
+	if err := config.WriteToFile(filename, tables); err != nil {
+		t.Fatalf("write to file: %v", err)
+	}
+	defer os.Remove(filename)
+
+	w, err := NewWatcher(filename, 0, syscall.SIGHUP)
+	if err != nil {
+		t.Fatalf("new watcher: %v", err)
+	}
+	defer w.Close()
+
+	tb, err := w.Lookup("account")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	tables = Tables{"account": {{Name: "0", Net: "tcp", Addr: "localhost:10001"}}}
+	if err = config.WriteToFile(filename, tables); err != nil {
+		t.Fatalf("write to file: %v", err)
+	}
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("find process: %v", err)
+	}
+	if err = p.Signal(syscall.SIGHUP); err != nil {
+		t.Skipf("signal: %v", err)
+	}
+	if err = WaitEndpoints(tb, "localhost:10001"); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+}
+
+func TestTableWatch(t *testing.T) {
+	tb := &Table{}
+	tb.setEndpoints([]endpoint.Endpoint{{Name: "0", Net: "tcp", Addr: "localhost:10000"}})
+
+	events := make(chan route.Event, 10)
+	cancel := tb.Watch(func(e route.Event) { events <- e })
+	defer cancel()
+
+	tb.setEndpoints([]endpoint.Endpoint{{Name: "0", Net: "tcp", Addr: "localhost:10000"}})
+	tb.setEndpoints([]endpoint.Endpoint{{Name: "1", Net: "tcp", Addr: "localhost:10001"}})
+	if got, want := len(events), 1; got != want {
+		t.Fatalf("events: got %v, want %v", got, want)
+	}
+	e := <-events
+	if len(e.Added) != 1 || e.Added[0].Name != "1" || len(e.Removed) != 1 || e.Removed[0].Name != "0" {
+		t.Errorf("unexpected event: %v", e)
+	}
+}
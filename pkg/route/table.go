@@ -1,7 +1,114 @@
 package route
 
-import "github.com/ironzhang/zerone/pkg/endpoint"
+import (
+	"sync"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+)
 
 type Table interface {
 	ListEndpoints() []endpoint.Endpoint
 }
+
+// Event 路由表变更事件, endpoint以Name为标识
+type Event struct {
+	Added   []endpoint.Endpoint // 新增的endpoint
+	Removed []endpoint.Endpoint // 删除的endpoint
+	Updated []endpoint.Endpoint // 变更后的endpoint
+}
+
+func (e Event) Empty() bool {
+	return len(e.Added) <= 0 && len(e.Removed) <= 0 && len(e.Updated) <= 0
+}
+
+// Diff 比较路由表变更前后的endpoints
+func Diff(olds, news []endpoint.Endpoint) Event {
+	var e Event
+	m := make(map[string]endpoint.Endpoint, len(olds))
+	for _, ep := range olds {
+		m[ep.Name] = ep
+	}
+	for _, ep := range news {
+		if old, ok := m[ep.Name]; !ok {
+			e.Added = append(e.Added, ep)
+		} else {
+			if !old.Equal(&ep) {
+				e.Updated = append(e.Updated, ep)
+			}
+			delete(m, ep.Name)
+		}
+	}
+	for _, ep := range olds {
+		if _, ok := m[ep.Name]; ok {
+			e.Removed = append(e.Removed, ep)
+		}
+	}
+	return e
+}
+
+type WatchFunc func(Event)
+
+// Watchable 支持变更通知的路由表
+type Watchable interface {
+	Table
+	Watch(f WatchFunc) (cancel func())
+}
+
+// Notifier 管理路由表的变更订阅, 零值可用
+type Notifier struct {
+	notify   sync.Mutex // 串行化通知, 保证订阅者按顺序收到事件
+	mu       sync.Mutex
+	token    int
+	watchers map[int]WatchFunc
+}
+
+func (n *Notifier) Watch(f WatchFunc) (cancel func()) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.watchers == nil {
+		n.watchers = make(map[int]WatchFunc)
+	}
+	n.token++
+	token := n.token
+	n.watchers[token] = f
+	return func() {
+		n.mu.Lock()
+		delete(n.watchers, token)
+		n.mu.Unlock()
+	}
+}
+
+// Notify 通知所有订阅者, 空事件不通知
+func (n *Notifier) Notify(e Event) {
+	if e.Empty() {
+		return
+	}
+
+	n.notify.Lock()
+	defer n.notify.Unlock()
+	n.dispatch(e)
+}
+
+// Update 在通知锁内调用diff计算变更事件并通知订阅者, 保证并发更新时订阅者收到的事件与计算顺序一致.
+// diff中更新路由表的endpoints并返回变更事件
+func (n *Notifier) Update(diff func() Event) {
+	n.notify.Lock()
+	defer n.notify.Unlock()
+	if e := diff(); !e.Empty() {
+		n.dispatch(e)
+	}
+}
+
+func (n *Notifier) dispatch(e Event) {
+	n.mu.Lock()
+	watchers := make([]WatchFunc, 0, len(n.watchers))
+	for _, f := range n.watchers {
+		watchers = append(watchers, f)
+	}
+	n.mu.Unlock()
+
+	for _, f := range watchers {
+		f(e)
+	}
+}
@@ -0,0 +1,62 @@
+package route
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+)
+
+func names(eps []endpoint.Endpoint) []string {
+	res := make([]string, 0, len(eps))
+	for _, ep := range eps {
+		res = append(res, ep.Name)
+	}
+	return res
+}
+
+func TestDiff(t *testing.T) {
+	olds := []endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:2000"},
+		{Name: "1", Net: "tcp", Addr: "localhost:2001"},
+		{Name: "2", Net: "tcp", Addr: "localhost:2002"},
+	}
+	news := []endpoint.Endpoint{
+		{Name: "1", Net: "tcp", Addr: "localhost:2001"},
+		{Name: "2", Net: "tcp", Addr: "localhost:3002"},
+		{Name: "3", Net: "tcp", Addr: "localhost:2003"},
+	}
+	e := Diff(olds, news)
+	if got, want := names(e.Added), []string{"3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("added: got %v, want %v", got, want)
+	}
+	if got, want := names(e.Removed), []string{"0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("removed: got %v, want %v", got, want)
+	}
+	if got, want := names(e.Updated), []string{"2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("updated: got %v, want %v", got, want)
+	}
+	if e = Diff(news, news); !e.Empty() {
+		t.Errorf("event is not empty: %v", e)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	var n Notifier
+	var events []Event
+	cancel := n.Watch(func(e Event) {
+		events = append(events, e)
+	})
+
+	n.Notify(Event{})
+	n.Notify(Event{Added: []endpoint.Endpoint{{Name: "0"}}})
+	cancel()
+	n.Notify(Event{Added: []endpoint.Endpoint{{Name: "1"}}})
+
+	if got, want := len(events), 1; got != want {
+		t.Fatalf("events: got %v, want %v", got, want)
+	}
+	if got, want := events[0].Added[0].Name, "0"; got != want {
+		t.Errorf("added: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,207 @@
+package rpc
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ironzhang/zerone/rpc/codec"
+	"github.com/ironzhang/zerone/rpc/codes"
+)
+
+// 认证类型常量定义
+const (
+	TokenCredentialType = "token"
+	HMACCredentialType  = "hmac"
+)
+
+// CredentialProvider 客户端凭证提供者, 为每个请求生成认证信息
+type CredentialProvider interface {
+	Credential(h *codec.RequestHeader) (codec.Credential, error)
+}
+
+// TokenCredential 以固定token作为认证信息
+type TokenCredential string
+
+func (t TokenCredential) Credential(h *codec.RequestHeader) (codec.Credential, error) {
+	return codec.Credential{Type: TokenCredentialType, Token: string(t)}, nil
+}
+
+// HMACCredential 以HMAC-SHA256对ClassMethod, Sequence, TraceID和时间戳签名作为认证信息
+type HMACCredential struct {
+	KeyID  string
+	Secret []byte
+}
+
+func (c HMACCredential) Credential(h *codec.RequestHeader) (codec.Credential, error) {
+	ts := time.Now().Unix()
+	return codec.Credential{
+		Type:      HMACCredentialType,
+		Principal: c.KeyID,
+		Timestamp: ts,
+		Token:     sign(c.Secret, h, ts),
+	}, nil
+}
+
+func sign(secret []byte, h *codec.RequestHeader, ts int64) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(h.ClassMethod))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(strconv.FormatUint(h.Sequence, 10)))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(h.TraceID))
+	mac.Write([]byte{'\n'})
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Authenticator 服务端认证器, 校验请求的认证信息并返回认证后的身份
+type Authenticator interface {
+	Authenticate(ctx context.Context, h *codec.RequestHeader) (principal string, err error)
+}
+
+var (
+	ErrNoCredential      = errors.New("no credential")
+	ErrInvalidCredential = errors.New("invalid credential")
+)
+
+// TokenAuthenticator 校验token认证信息, key为token, value为token对应的身份
+type TokenAuthenticator map[string]string
+
+func (a TokenAuthenticator) Authenticate(ctx context.Context, h *codec.RequestHeader) (string, error) {
+	if h.Credential.Type != TokenCredentialType {
+		return "", ErrNoCredential
+	}
+	principal, ok := a[h.Credential.Token]
+	if !ok {
+		return "", ErrInvalidCredential
+	}
+	return principal, nil
+}
+
+// HMACAuthenticator 校验HMAC签名, 认证后的身份为密钥ID
+type HMACAuthenticator struct {
+	Secrets map[string][]byte // 密钥ID到密钥的映射
+	MaxSkew time.Duration     // 签名时间与服务器时间允许的最大偏差, 默认为5分钟
+}
+
+func (a HMACAuthenticator) Authenticate(ctx context.Context, h *codec.RequestHeader) (string, error) {
+	if h.Credential.Type != HMACCredentialType {
+		return "", ErrNoCredential
+	}
+	secret, ok := a.Secrets[h.Credential.Principal]
+	if !ok {
+		return "", fmt.Errorf("unknown key id %q", h.Credential.Principal)
+	}
+	skew := a.MaxSkew
+	if skew <= 0 {
+		skew = 5 * time.Minute
+	}
+	if d := time.Since(time.Unix(h.Credential.Timestamp, 0)); d > skew || d < -skew {
+		return "", fmt.Errorf("timestamp skew %v exceeds %v", d, skew)
+	}
+	if !hmac.Equal([]byte(sign(secret, h, h.Credential.Timestamp)), []byte(h.Credential.Token)) {
+		return "", ErrInvalidCredential
+	}
+	return h.Credential.Principal, nil
+}
+
+// ACL 按方法控制访问权限, key为class.method, class.*或*, value为允许访问的身份, 其中*表示任意身份.
+// 按class.method, class.*, *的顺序匹配第一条规则, 没有匹配的规则时允许访问
+type ACL map[string][]string
+
+func (a ACL) Allow(principal, classMethod string) bool {
+	keys := []string{classMethod}
+	if className, _, err := splitClassMethod(classMethod); err == nil {
+		keys = append(keys, className+".*")
+	}
+	keys = append(keys, "*")
+
+	for _, key := range keys {
+		principals, ok := a[key]
+		if !ok {
+			continue
+		}
+		for _, p := range principals {
+			if p == "*" || p == principal {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// SetCredentialProvider 设置凭证提供者, 之后的每个请求都会带上认证信息
+func (c *Client) SetCredentialProvider(p CredentialProvider) {
+	c.credentials.Store(credentialHolder{p})
+}
+
+type credentialHolder struct {
+	provider CredentialProvider
+}
+
+func (c *Client) sign(h *codec.RequestHeader) error {
+	v, ok := c.credentials.Load().(credentialHolder)
+	if !ok || v.provider == nil {
+		return nil
+	}
+	cred, err := v.provider.Credential(h)
+	if err != nil {
+		return NewError(codes.Unauthenticated, err)
+	}
+	h.Credential = cred
+	return nil
+}
+
+// SetAuthenticator 设置认证器, 认证失败的请求以codes.Unauthenticated拒绝, 心跳请求不做认证
+func (s *Server) SetAuthenticator(a Authenticator) {
+	s.auth.Store(authHolder{a})
+}
+
+// SetACL 设置方法的访问控制列表, 没有权限的请求以codes.PermissionDenied拒绝, 需要同时设置认证器
+func (s *Server) SetACL(acl ACL) {
+	s.acl.Store(acl)
+}
+
+type authHolder struct {
+	authenticator Authenticator
+}
+
+// authenticate 认证请求并检查访问权限, 认证成功后将身份记录到ctx中
+func (s *Server) authenticate(ctx context.Context, req *codec.RequestHeader) (context.Context, error) {
+	v, ok := s.auth.Load().(authHolder)
+	if !ok || v.authenticator == nil {
+		return ctx, nil
+	}
+	principal, err := v.authenticator.Authenticate(ctx, req)
+	if err != nil {
+		return ctx, NewError(codes.Unauthenticated, err)
+	}
+	ctx = WithPrincipal(ctx, principal)
+	// 批量调用在执行时逐个检查访问权限
+	if req.ClassMethod == BatchMethod {
+		return ctx, nil
+	}
+	if err = s.authorize(ctx, req.ClassMethod); err != nil {
+		return ctx, err
+	}
+	return ctx, nil
+}
+
+// authorize 检查ctx中认证后的身份是否有权限调用classMethod, 未设置认证器时不检查
+func (s *Server) authorize(ctx context.Context, classMethod string) error {
+	principal, ok := ParsePrincipal(ctx)
+	if !ok {
+		return nil
+	}
+	if acl, ok := s.acl.Load().(ACL); ok && !acl.Allow(principal, classMethod) {
+		return Errorf(codes.PermissionDenied, "%s is not allowed to call %s", principal, classMethod)
+	}
+	return nil
+}
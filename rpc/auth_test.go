@@ -0,0 +1,136 @@
+package rpc_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/ironzhang/zerone/rpc"
+	"github.com/ironzhang/zerone/rpc/codes"
+	"github.com/ironzhang/zerone/rpc/trace"
+)
+
+type Principal int
+
+func (Principal) Whoami(ctx context.Context, args interface{}, reply *string) error {
+	*reply, _ = rpc.ParsePrincipal(ctx)
+	return nil
+}
+
+func (Principal) Admin(ctx context.Context, args interface{}, reply *string) error {
+	*reply = "ok"
+	return nil
+}
+
+func TestACL(t *testing.T) {
+	acl := rpc.ACL{
+		"Principal.Admin": {"root"},
+		"Principal.*":     {"*"},
+		"*":               {"root", "alice"},
+	}
+	tests := []struct {
+		principal   string
+		classMethod string
+		allow       bool
+	}{
+		{principal: "root", classMethod: "Principal.Admin", allow: true},
+		{principal: "alice", classMethod: "Principal.Admin", allow: false},
+		{principal: "alice", classMethod: "Principal.Whoami", allow: true},
+		{principal: "bob", classMethod: "Principal.Whoami", allow: true},
+		{principal: "alice", classMethod: "Arith.Multiply", allow: true},
+		{principal: "bob", classMethod: "Arith.Multiply", allow: false},
+	}
+	for i, tt := range tests {
+		if got, want := acl.Allow(tt.principal, tt.classMethod), tt.allow; got != want {
+			t.Errorf("%d: allow(%s, %s): got %v, want %v", i, tt.principal, tt.classMethod, got, want)
+		}
+	}
+	if !(rpc.ACL{}).Allow("bob", "Arith.Multiply") {
+		t.Errorf("empty acl should allow all")
+	}
+}
+
+func ServeAuth(t *testing.T, auth rpc.Authenticator, acl rpc.ACL) net.Listener {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	svr := rpc.NewServer("AuthServer")
+	if err = svr.Register(new(Principal)); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svr.SetAuthenticator(auth)
+	svr.SetACL(acl)
+	svr.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	go svr.Accept(ln)
+	return ln
+}
+
+func errorCode(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	if e, ok := err.(rpc.ErrorCode); ok {
+		return e.Code()
+	}
+	return codes.Unknown
+}
+
+func TestAuthentication(t *testing.T) {
+	secret := []byte("secret")
+	auth := rpc.HMACAuthenticator{Secrets: map[string][]byte{"alice": secret}}
+	ln := ServeAuth(t, auth, rpc.ACL{"Principal.Admin": {"root"}})
+	defer ln.Close()
+
+	tests := []struct {
+		name        string
+		credentials rpc.CredentialProvider
+		method      string
+		code        codes.Code
+		reply       string
+	}{
+		{name: "NoCredential", credentials: nil, method: "Principal.Whoami", code: codes.Unauthenticated},
+		{name: "Token", credentials: rpc.TokenCredential("token"), method: "Principal.Whoami", code: codes.Unauthenticated},
+		{name: "WrongSecret", credentials: rpc.HMACCredential{KeyID: "alice", Secret: []byte("wrong")}, method: "Principal.Whoami", code: codes.Unauthenticated},
+		{name: "HMAC", credentials: rpc.HMACCredential{KeyID: "alice", Secret: secret}, method: "Principal.Whoami", code: codes.OK, reply: "alice"},
+		{name: "PermissionDenied", credentials: rpc.HMACCredential{KeyID: "alice", Secret: secret}, method: "Principal.Admin", code: codes.PermissionDenied},
+	}
+	for _, tt := range tests {
+		c, err := rpc.DialWithOptions(tt.name, "tcp", ln.Addr().String(), rpc.DialOptions{Credentials: tt.credentials})
+		if err != nil {
+			t.Fatalf("%s: dial: %v", tt.name, err)
+		}
+		c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+
+		var reply string
+		err = c.Call(context.Background(), tt.method, nil, &reply, 0)
+		if got, want := errorCode(err), tt.code; got != want {
+			t.Errorf("%s: code: got %v, want %v, err=%v", tt.name, got, want, err)
+		}
+		if got, want := reply, tt.reply; got != want {
+			t.Errorf("%s: reply: got %q, want %q", tt.name, got, want)
+		}
+		c.Close()
+	}
+}
+
+func TestTokenAuthentication(t *testing.T) {
+	ln := ServeAuth(t, rpc.TokenAuthenticator{"token": "bob"}, nil)
+	defer ln.Close()
+
+	c, err := rpc.DialWithOptions("TestTokenAuthentication", "tcp", ln.Addr().String(), rpc.DialOptions{Credentials: rpc.TokenCredential("token")})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+
+	var reply string
+	if err = c.Call(context.Background(), "Principal.Whoami", nil, &reply, 0); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got, want := reply, "bob"; got != want {
+		t.Errorf("reply: got %q, want %q", got, want)
+	}
+}
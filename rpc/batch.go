@@ -0,0 +1,190 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/ironzhang/zerone/rpc/codec"
+	"github.com/ironzhang/zerone/rpc/codes"
+)
+
+// BatchMethod rpc.Server自动提供的批量调用方法, 多个调用打包在一个请求中发送
+const BatchMethod = "rpc.Batch.Call"
+
+// BatchCall 批量调用中的单个调用, 批量调用完成后Reply和Error被填充
+type BatchCall struct {
+	ClassMethod string
+	Args        interface{}
+	Reply       interface{}
+	Error       error
+}
+
+// BatchItem 批量调用请求中的单个调用
+type BatchItem struct {
+	ClassMethod string
+	Args        json.RawMessage
+}
+
+// BatchArgs 批量调用的请求
+type BatchArgs struct {
+	Parallel bool // 服务端并行执行各个调用
+	Calls    []BatchItem
+}
+
+// BatchResult 批量调用应答中单个调用的结果, Error.Code不为0时表示调用失败
+type BatchResult struct {
+	Error codec.Error
+	Reply json.RawMessage
+}
+
+type batch struct{}
+
+func (batch) Call(ctx context.Context, args *BatchArgs, reply *[]BatchResult) error {
+	// 由Server.serveBatch处理, 注册该方法只为解析请求
+	return nil
+}
+
+func (s *Server) registerBatch() {
+	c, err := parseClass("rpc.Batch", reflect.ValueOf(batch{}))
+	if err != nil {
+		panic(err)
+	}
+	s.classMap.Store(c.name, c)
+}
+
+// serveBatch 执行批量调用, 每个调用单独检查访问权限并以相同的TraceID输出trace, 所有调用完成后一起应答
+func (s *Server) serveBatch(ctx context.Context, c codec.ServerCodec, req *codec.RequestHeader, args *BatchArgs) {
+	results := make([]BatchResult, len(args.Calls))
+	if args.Parallel {
+		var wg sync.WaitGroup
+		for i := range args.Calls {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = s.batchCall(ctx, req, args.Calls[i])
+			}(i)
+		}
+		wg.Wait()
+	} else {
+		for i := range args.Calls {
+			results[i] = s.batchCall(ctx, req, args.Calls[i])
+		}
+	}
+	if !req.OneWay {
+		s.writeResponse(c, req, results, nil)
+	}
+}
+
+func (s *Server) batchCall(ctx context.Context, req *codec.RequestHeader, item BatchItem) BatchResult {
+	h := *req
+	h.ClassMethod = item.ClassMethod
+	tr := s.logger.NewTrace(true, h.Verbose, h.TraceID, h.ClientName, "", s.name, "", h.ClassMethod)
+
+	rcvr, meth, err := s.lookupBatchMethod(ctx, h.ClassMethod)
+	if err != nil {
+		tr.Request(nil)
+		tr.Response(s.rpcError(err), emptyResp)
+		return BatchResult{Error: s.responseError(err)}
+	}
+	args, reply, err := newArgsReply(meth, func(x interface{}) error {
+		return json.Unmarshal(item.Args, x)
+	})
+	if err != nil {
+		tr.Request(nil)
+		tr.Response(s.rpcError(err), emptyResp)
+		return BatchResult{Error: s.responseError(err)}
+	}
+
+	tr.Request(args.Interface())
+	err = s.call(ctx, &h, meth.method, rcvr, args, reply)
+	tr.Response(s.rpcError(err), reply.Interface())
+	if err != nil {
+		return BatchResult{Error: s.responseError(err)}
+	}
+	data, err := json.Marshal(reply.Interface())
+	if err != nil {
+		return BatchResult{Error: s.responseError(NewError(codes.InvalidResponse, err))}
+	}
+	return BatchResult{Reply: data}
+}
+
+// lookupBatchMethod 查找批量调用中的方法并检查访问权限, 不支持流方法和嵌套的批量调用
+func (s *Server) lookupBatchMethod(ctx context.Context, classMethod string) (reflect.Value, *method, error) {
+	className, methodName, err := splitClassMethod(classMethod)
+	if err != nil {
+		return reflect.Value{}, nil, NewError(codes.InvalidHeader, err)
+	}
+	rcvr, meth, err := s.lookupClassMethod(className, methodName)
+	if err != nil {
+		return reflect.Value{}, nil, NewError(codes.InvalidHeader, err)
+	}
+	if meth.stream || classMethod == BatchMethod {
+		return reflect.Value{}, nil, Errorf(codes.InvalidHeader, "method %s is not allowed in batch", classMethod)
+	}
+	if err = s.authorize(ctx, classMethod); err != nil {
+		return reflect.Value{}, nil, err
+	}
+	return rcvr, meth, nil
+}
+
+func newBatchArgs(calls []*BatchCall, parallel bool) (*BatchArgs, error) {
+	args := &BatchArgs{Parallel: parallel, Calls: make([]BatchItem, 0, len(calls))}
+	for _, call := range calls {
+		data, err := json.Marshal(call.Args)
+		if err != nil {
+			return nil, fmt.Errorf("marshal %s args: %v", call.ClassMethod, err)
+		}
+		args.Calls = append(args.Calls, BatchItem{ClassMethod: call.ClassMethod, Args: data})
+	}
+	return args, nil
+}
+
+func unpackBatch(calls []*BatchCall, results []BatchResult) error {
+	if len(results) != len(calls) {
+		return Errorf(codes.InvalidResponse, "batch got %d results, want %d", len(results), len(calls))
+	}
+	for i, res := range results {
+		call := calls[i]
+		if res.Error.Code != 0 {
+			call.Error = ServerErrorf(res.Error.ServerName, codes.Code(res.Error.Code), res.Error.Cause)
+			continue
+		}
+		call.Error = nil
+		if call.Reply != nil {
+			if err := json.Unmarshal(res.Reply, call.Reply); err != nil {
+				call.Error = NewError(codes.InvalidResponse, err)
+			}
+		}
+	}
+	return nil
+}
+
+// GoBatch 异步批量调用, parallel为true时服务端并行执行各个调用.
+// 返回的Call完成时各BatchCall的Reply和Error已被填充, Call.Error只表示整个批量调用的错误, 如超时或连接断开
+func (c *Client) GoBatch(ctx context.Context, calls []*BatchCall, parallel bool, timeout time.Duration, done chan *Call) (*Call, error) {
+	args, err := newBatchArgs(calls, parallel)
+	if err != nil {
+		return nil, err
+	}
+	results := []BatchResult{}
+	ctx = WithCallDone(ctx, func(call *Call) {
+		if call.Error == nil {
+			call.Error = unpackBatch(calls, results)
+		}
+	})
+	return c.Go(ctx, BatchMethod, args, &results, timeout, done)
+}
+
+// Batch 同步批量调用, 各个调用的结果见BatchCall.Error
+func (c *Client) Batch(ctx context.Context, calls []*BatchCall, parallel bool, timeout time.Duration) error {
+	call, err := c.GoBatch(ctx, calls, parallel, timeout, make(chan *Call, 1))
+	if err != nil {
+		return err
+	}
+	<-call.Done
+	return call.Error
+}
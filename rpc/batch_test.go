@@ -0,0 +1,125 @@
+package rpc_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/rpc"
+	"github.com/ironzhang/zerone/rpc/codes"
+	"github.com/ironzhang/zerone/rpc/trace"
+)
+
+type TraceOutput struct {
+	mu        sync.Mutex
+	responses []trace.Response
+}
+
+func (p *TraceOutput) Request(r trace.Request) {
+}
+
+func (p *TraceOutput) Response(r trace.Response) {
+	p.mu.Lock()
+	p.responses = append(p.responses, r)
+	p.mu.Unlock()
+}
+
+func TestBatch(t *testing.T) {
+	out := &TraceOutput{}
+	svr := rpc.NewServer("TestBatch")
+	if err := svr.Register(new(Arith)); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := svr.Register(&Streamer{}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svr.SetTraceOutput(out)
+	svr.SetTraceVerbose(1)
+	cc, sc := net.Pipe()
+	go svr.ServeConn(sc)
+	c := rpc.NewClient("TestBatch", cc)
+	defer c.Close()
+	c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+
+	for _, parallel := range []bool{false, true} {
+		var product int
+		var quo Quotient
+		calls := []*rpc.BatchCall{
+			{ClassMethod: "Arith.Multiply", Args: Args{7, 8}, Reply: &product},
+			{ClassMethod: "Arith.Divide", Args: Args{7, 2}, Reply: &quo},
+			{ClassMethod: "Arith.Divide", Args: Args{7, 0}, Reply: &Quotient{}},
+			{ClassMethod: "Arith.NotFound", Args: nil},
+			{ClassMethod: "Streamer.Echo", Args: nil},
+			{ClassMethod: rpc.BatchMethod, Args: nil},
+		}
+		ctx := rpc.WithTraceID(context.Background(), "batch-trace")
+		if err := c.Batch(ctx, calls, parallel, time.Second); err != nil {
+			t.Fatalf("parallel(%v): batch: %v", parallel, err)
+		}
+		if product != 56 {
+			t.Errorf("parallel(%v): product: got %v, want %v", parallel, product, 56)
+		}
+		if want := (Quotient{3, 1}); quo != want {
+			t.Errorf("parallel(%v): quotient: got %v, want %v", parallel, quo, want)
+		}
+		wants := []codes.Code{codes.OK, codes.OK, codes.Unknown, codes.InvalidHeader, codes.InvalidHeader, codes.InvalidHeader}
+		for i, want := range wants {
+			if got := errorCode(calls[i].Error); got != want {
+				t.Errorf("parallel(%v): %d: code: got %v, want %v", parallel, i, got, want)
+			}
+		}
+	}
+
+	// 每个子调用输出一条trace, TraceID相同
+	out.mu.Lock()
+	responses := out.responses
+	out.mu.Unlock()
+	if got, want := len(responses), 12; got != want {
+		t.Fatalf("traces: got %v, want %v", got, want)
+	}
+	for i, r := range responses {
+		if r.TraceID != "batch-trace" {
+			t.Errorf("%d: trace id: got %v, want %v", i, r.TraceID, "batch-trace")
+		}
+		if r.ClassMethod == rpc.BatchMethod && r.Error == nil {
+			t.Errorf("%d: unexpected batch trace", i)
+		}
+	}
+}
+
+func TestBatchACL(t *testing.T) {
+	svr := rpc.NewServer("TestBatchACL")
+	if err := svr.Register(new(Arith)); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svr.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	svr.SetAuthenticator(rpc.TokenAuthenticator{"secret": "alice"})
+	svr.SetACL(rpc.ACL{"Arith.Divide": {"root"}})
+	cc, sc := net.Pipe()
+	go svr.ServeConn(sc)
+	c := rpc.NewClient("TestBatchACL", cc)
+	defer c.Close()
+	c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	c.SetCredentialProvider(rpc.TokenCredential("secret"))
+
+	var product int
+	calls := []*rpc.BatchCall{
+		{ClassMethod: "Arith.Multiply", Args: Args{2, 3}, Reply: &product},
+		{ClassMethod: "Arith.Divide", Args: Args{6, 3}, Reply: &Quotient{}},
+	}
+	if err := c.Batch(context.Background(), calls, false, time.Second); err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	if got, want := errorCode(calls[0].Error), codes.OK; got != want {
+		t.Errorf("multiply: got %v, want %v", got, want)
+	}
+	if product != 6 {
+		t.Errorf("product: got %v, want %v", product, 6)
+	}
+	if got, want := errorCode(calls[1].Error), codes.PermissionDenied; got != want {
+		t.Errorf("divide: got %v, want %v", got, want)
+	}
+}
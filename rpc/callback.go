@@ -0,0 +1,225 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ironzhang/pearls/uuid"
+	log "github.com/ironzhang/tlog"
+	"github.com/ironzhang/zerone/rpc/codec"
+	"github.com/ironzhang/zerone/rpc/codes"
+	"github.com/ironzhang/zerone/rpc/trace"
+)
+
+// Caller 反向调用句柄, 服务端方法通过ParseCaller获取, 用于调用发起请求的客户端上注册的接收者.
+// 反向调用复用已有的连接, 连接断开后所有未完成的反向调用以ErrShutdown结束
+type Caller struct {
+	server   *Server
+	codec    codec.ServerCodec
+	sequence uint64
+
+	mu      sync.Mutex
+	pending map[uint64]*Call
+	closed  bool
+}
+
+func newCaller(s *Server, c codec.ServerCodec) *Caller {
+	return &Caller{server: s, codec: c, pending: make(map[uint64]*Call)}
+}
+
+// Go 异步调用客户端的方法
+func (p *Caller) Go(ctx context.Context, classMethod string, args interface{}, reply interface{}, timeout time.Duration, done chan *Call) (*Call, error) {
+	if done == nil {
+		done = make(chan *Call, 10)
+	} else {
+		if cap(done) == 0 {
+			log.Panic("rpc: done channel is unbuffered")
+		}
+	}
+
+	sequence := atomic.AddUint64(&p.sequence, 1)
+	verbose, _ := ParseVerbose(ctx)
+	traceID, ok := ParseTraceID(ctx)
+	if !ok {
+		traceID = uuid.New().String()
+	}
+
+	call := &Call{
+		Header: codec.RequestHeader{
+			ClassMethod: classMethod,
+			Sequence:    sequence,
+			ClientName:  p.server.name,
+			TraceID:     traceID,
+			Verbose:     verbose,
+		},
+		Args:  args,
+		Reply: reply,
+		Done:  done,
+		trace: p.server.logger.NewTrace(false, verbose, traceID, p.server.name, "", "", "", classMethod),
+	}
+	// 加入pending前记录请求, 加入后call可能随时被close完成
+	call.trace.Request(args)
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return nil, ErrShutdown
+	}
+	p.pending[sequence] = call
+	p.mu.Unlock()
+	h := codec.ResponseHeader{
+		ClassMethod: classMethod,
+		Sequence:    sequence,
+		Reverse:     true,
+		TraceID:     traceID,
+		Verbose:     verbose,
+	}
+	if err := p.codec.WriteResponse(&h, args); err != nil {
+		p.removeCall(sequence)
+		return nil, err
+	}
+
+	// 超时处理
+	if timeout > 0 {
+		time.AfterFunc(timeout, func() {
+			if call, ok := p.removeCall(sequence); ok {
+				call.Error = ErrTimeout
+				call.done()
+			}
+		})
+	}
+
+	return call, nil
+}
+
+// Call 同步调用客户端的方法
+func (p *Caller) Call(ctx context.Context, classMethod string, args interface{}, reply interface{}, timeout time.Duration) error {
+	call, err := p.Go(ctx, classMethod, args, reply, timeout, make(chan *Call, 1))
+	if err != nil {
+		return err
+	}
+	<-call.Done
+	return call.Error
+}
+
+func (p *Caller) removeCall(sequence uint64) (*Call, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	call, ok := p.pending[sequence]
+	if ok {
+		delete(p.pending, sequence)
+	}
+	return call, ok
+}
+
+// readResponse 读取客户端对反向调用的应答
+func (p *Caller) readResponse(req *codec.RequestHeader) error {
+	call, ok := p.removeCall(req.Sequence)
+	if !ok {
+		p.codec.ReadRequestBody(nil)
+		return fmt.Errorf("reverse sequence(%d) not found", req.Sequence)
+	}
+
+	var err error
+	if req.Error.Code != 0 {
+		err = p.codec.ReadRequestBody(nil)
+		call.Error = ServerErrorf(req.Error.ServerName, codes.Code(req.Error.Code), req.Error.Cause)
+	} else if err = p.codec.ReadRequestBody(call.Reply); err != nil {
+		call.Error = NewError(codes.InvalidResponse, err)
+	}
+	call.done()
+	return err
+}
+
+// close 连接断开时以err结束所有未完成的反向调用
+func (p *Caller) close(err error) {
+	p.mu.Lock()
+	pending := p.pending
+	p.pending = make(map[uint64]*Call)
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, call := range pending {
+		call.Error = err
+		call.done()
+	}
+}
+
+type keyCaller struct{}
+
+func withCaller(ctx context.Context, caller *Caller) context.Context {
+	return context.WithValue(ctx, keyCaller{}, caller)
+}
+
+// ParseCaller 获取调用当前客户端的反向调用句柄, 只有通过连接服务的请求才有
+func ParseCaller(ctx context.Context) (*Caller, bool) {
+	value := ctx.Value(keyCaller{})
+	if caller, ok := value.(*Caller); ok {
+		return caller, true
+	}
+	return nil, false
+}
+
+// Register 注册反向调用的接收者, 方法签名与服务端方法相同
+func (c *Client) Register(rcvr interface{}) error {
+	return c.receivers.Register(rcvr)
+}
+
+// RegisterName 以name注册反向调用的接收者
+func (c *Client) RegisterName(name string, rcvr interface{}) error {
+	return c.receivers.RegisterName(name, rcvr)
+}
+
+// serveCallback 处理服务端发来的反向调用, 在独立的goroutine中调用接收者的方法
+func (c *Client) serveCallback(resp *codec.ResponseHeader) error {
+	req := &codec.RequestHeader{
+		ClassMethod: resp.ClassMethod,
+		Sequence:    resp.Sequence,
+		ClientName:  c.name,
+		TraceID:     resp.TraceID,
+		Verbose:     resp.Verbose,
+		Reverse:     true,
+	}
+	tr := c.logger.NewTrace(true, req.Verbose, req.TraceID, "", "", c.name, "", req.ClassMethod)
+
+	className, methodName, err := splitClassMethod(req.ClassMethod)
+	if err != nil {
+		c.codec.ReadResponseBody(nil)
+		go c.writeCallbackError(tr, req, NewError(codes.InvalidHeader, err))
+		return nil
+	}
+	rcvr, meth, err := c.receivers.lookupClassMethod(className, methodName)
+	if err == nil && meth.stream {
+		err = fmt.Errorf("method %s is a stream method", req.ClassMethod)
+	}
+	if err != nil {
+		c.codec.ReadResponseBody(nil)
+		go c.writeCallbackError(tr, req, NewError(codes.InvalidHeader, err))
+		return nil
+	}
+	args, reply, err := newArgsReply(meth, c.codec.ReadResponseBody)
+	if err != nil {
+		go c.writeCallbackError(tr, req, err)
+		return nil
+	}
+
+	go func() {
+		tr.Request(args.Interface())
+		err := c.receivers.call(context.Background(), req, meth.method, rcvr, args, reply)
+		h := *req
+		h.Error = c.receivers.responseError(err)
+		c.codec.WriteRequest(&h, reply.Interface())
+		tr.Response(c.receivers.rpcError(err), reply.Interface())
+	}()
+	return nil
+}
+
+func (c *Client) writeCallbackError(tr trace.Trace, req *codec.RequestHeader, err error) {
+	tr.Request(nil)
+	h := *req
+	h.Error = c.receivers.responseError(err)
+	c.codec.WriteRequest(&h, emptyResp)
+	tr.Response(c.receivers.rpcError(err), emptyResp)
+}
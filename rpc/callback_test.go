@@ -0,0 +1,147 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/rpc"
+	"github.com/ironzhang/zerone/rpc/codes"
+	"github.com/ironzhang/zerone/rpc/trace"
+)
+
+type Progress struct {
+	mu      sync.Mutex
+	reports []int
+	block   chan struct{}
+}
+
+func (p *Progress) Report(ctx context.Context, args int, reply *int) error {
+	p.mu.Lock()
+	p.reports = append(p.reports, args)
+	p.mu.Unlock()
+	*reply = args * 2
+	return nil
+}
+
+func (p *Progress) Block(ctx context.Context, args interface{}, reply interface{}) error {
+	<-p.block
+	return nil
+}
+
+type Job struct {
+	blocked chan error
+}
+
+// Run 回调客户端的Progress.Report n次, 返回回调结果的总和
+func (j *Job) Run(ctx context.Context, n int, reply *int) error {
+	caller, ok := rpc.ParseCaller(ctx)
+	if !ok {
+		return errors.New("caller not found")
+	}
+	for i := 0; i < n; i++ {
+		var r int
+		if err := caller.Call(ctx, "Progress.Report", i, &r, time.Second); err != nil {
+			return err
+		}
+		*reply += r
+	}
+	return nil
+}
+
+func (j *Job) Call(ctx context.Context, method string, reply interface{}) error {
+	caller, _ := rpc.ParseCaller(ctx)
+	return caller.Call(ctx, method, nil, nil, time.Second)
+}
+
+func (j *Job) Block(ctx context.Context, args interface{}, reply interface{}) error {
+	caller, _ := rpc.ParseCaller(ctx)
+	j.blocked <- caller.Call(ctx, "Progress.Block", nil, nil, 0)
+	return nil
+}
+
+func newCallbackClient(t *testing.T, j *Job, p *Progress) *rpc.Client {
+	svr := rpc.NewServer("CallbackServer")
+	if err := svr.Register(j); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svr.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	cc, sc := net.Pipe()
+	go svr.ServeConn(sc)
+	c := rpc.NewClient("CallbackClient", cc)
+	c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	if err := c.Register(p); err != nil {
+		t.Fatalf("register receiver: %v", err)
+	}
+	return c
+}
+
+func TestCallback(t *testing.T) {
+	p := &Progress{}
+	c := newCallbackClient(t, &Job{}, p)
+	defer c.Close()
+
+	var reply int
+	if err := c.Call(context.Background(), "Job.Run", 3, &reply, time.Second); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got, want := reply, 6; got != want {
+		t.Errorf("reply: got %v, want %v", got, want)
+	}
+	p.mu.Lock()
+	reports := p.reports
+	p.mu.Unlock()
+	if got, want := len(reports), 3; got != want {
+		t.Fatalf("reports: got %v, want %v", got, want)
+	}
+	for i, r := range reports {
+		if r != i {
+			t.Errorf("%d: got %v, want %v", i, r, i)
+		}
+	}
+}
+
+func TestCallbackError(t *testing.T) {
+	c := newCallbackClient(t, &Job{}, &Progress{})
+	defer c.Close()
+
+	tests := []struct {
+		method string
+		code   codes.Code
+	}{
+		{method: "Progress.NotFound", code: codes.InvalidHeader},
+		{method: "Unknown.Report", code: codes.InvalidHeader},
+		{method: "Progress", code: codes.InvalidHeader},
+	}
+	for i, tt := range tests {
+		err := c.Call(context.Background(), "Job.Call", tt.method, nil, time.Second)
+		if code := errorCode(err); code != tt.code {
+			t.Errorf("%d: code: got %v, want %v", i, code, tt.code)
+		}
+	}
+}
+
+func TestCallbackShutdown(t *testing.T) {
+	j := &Job{blocked: make(chan error, 1)}
+	p := &Progress{block: make(chan struct{})}
+	defer close(p.block)
+	c := newCallbackClient(t, j, p)
+
+	if _, err := c.Go(context.Background(), "Job.Block", nil, nil, 0, nil); err != nil {
+		t.Fatalf("go: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	c.Close()
+	select {
+	case err := <-j.blocked:
+		if err != rpc.ErrShutdown {
+			t.Errorf("callback: got %v, want %v", err, rpc.ErrShutdown)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("callback is not finished")
+	}
+}
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -15,6 +16,7 @@ import (
 	"github.com/ironzhang/zerone/rpc/codec"
 	"github.com/ironzhang/zerone/rpc/codec/json_codec"
 	"github.com/ironzhang/zerone/rpc/codes"
+	"github.com/ironzhang/zerone/rpc/memconn"
 	"github.com/ironzhang/zerone/rpc/trace"
 )
 
@@ -32,12 +34,16 @@ type Call struct {
 	Done   chan *Call
 
 	trace trace.Trace
+	hook  func(*Call)
 }
 
 func (c *Call) done() {
 	if c.trace != nil {
 		c.trace.Response(c.Error, c.Reply)
 	}
+	if c.hook != nil {
+		c.hook(c)
+	}
 	select {
 	case c.Done <- c:
 		// ok
@@ -47,12 +53,16 @@ func (c *Call) done() {
 }
 
 func (c *Call) send(codec codec.ClientCodec) error {
-	if err := codec.WriteRequest(&c.Header, c.Args); err != nil {
-		return err
-	}
+	// 写请求之前记录trace, 避免与读取响应的goroutine竞争
 	if c.trace != nil {
 		c.trace.Request(c.Args)
 	}
+	if err := codec.WriteRequest(&c.Header, c.Args); err != nil {
+		if c.trace != nil {
+			c.trace.Response(err, nil)
+		}
+		return err
+	}
 	return nil
 }
 
@@ -61,18 +71,93 @@ type Client struct {
 	codec  codec.ClientCodec
 	logger *trace.Logger
 
+	receivers   *Server    // 反向调用的接收者
+	mu          sync.Mutex // 保证pending的增删与npending的计数一致, 同时保护streams
 	pending     sync.Map
+	streams     map[uint64]*stream
+	npending    int64
+	credentials atomic.Value
 	sequence    uint64
 	shutdown    int32
 	unavailable int32
 }
 
+// DialOptions 建立连接的选项
+type DialOptions struct {
+	Timeout   time.Duration    // 建立连接的超时时间, 为0时不超时
+	KeepAlive time.Duration    // TCP keepalive探测间隔, 为0时使用系统默认值, 小于0时关闭keepalive
+	LocalAddr net.Addr         // 本地地址, 为nil时自动选择
+	Heartbeat HeartbeatOptions // 心跳选项
+	TLSConfig *tls.Config      // TLS配置, 不为nil时建立TLS连接, 未设置ServerName时使用address中的主机名
+
+	Credentials CredentialProvider // 凭证提供者, 不为nil时每个请求都会带上认证信息
+
+	// Dialer 自定义建立连接的函数, 用于测试及代理等场景, 设置后忽略KeepAlive和LocalAddr
+	Dialer func(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+func (o *DialOptions) dial(network, address string) (net.Conn, error) {
+	ctx := context.Background()
+	if o.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.Timeout)
+		defer cancel()
+	}
+	var conn net.Conn
+	var err error
+	if o.Dialer != nil {
+		conn, err = o.Dialer(ctx, network, address)
+	} else if network == memconn.Network {
+		conn, err = memconn.Dial(ctx, address)
+	} else {
+		d := net.Dialer{KeepAlive: o.KeepAlive, LocalAddr: o.LocalAddr}
+		conn, err = d.DialContext(ctx, network, address)
+	}
+	if err != nil || o.TLSConfig == nil {
+		return conn, err
+	}
+	return o.handshake(ctx, conn, address)
+}
+
+func (o *DialOptions) handshake(ctx context.Context, conn net.Conn, address string) (net.Conn, error) {
+	config := o.TLSConfig
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			host = address
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+
+	tc := tls.Client(conn, config)
+	if deadline, ok := ctx.Deadline(); ok {
+		tc.SetDeadline(deadline)
+		defer tc.SetDeadline(time.Time{})
+	}
+	if err := tc.Handshake(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tc, nil
+}
+
 func Dial(name, network, address string) (*Client, error) {
-	conn, err := net.Dial(network, address)
+	return DialWithOptions(name, network, address, DialOptions{})
+}
+
+// DialWithOptions 按选项建立连接, network为memconn.Network时连接进程内的内存地址
+func DialWithOptions(name, network, address string, opts DialOptions) (*Client, error) {
+	conn, err := opts.dial(network, address)
 	if err != nil {
 		return nil, err
 	}
-	return NewClient(name, conn), nil
+	c := NewClient(name, conn)
+	if opts.Credentials != nil {
+		c.SetCredentialProvider(opts.Credentials)
+	}
+	c.StartHeartbeat(opts.Heartbeat)
+	return c, nil
 }
 
 func NewClient(name string, rwc io.ReadWriteCloser) *Client {
@@ -80,10 +165,12 @@ func NewClient(name string, rwc io.ReadWriteCloser) *Client {
 }
 
 func NewClientWithCodec(name string, c codec.ClientCodec) *Client {
+	logger := trace.NewLogger()
 	client := &Client{
-		name:   name,
-		codec:  c,
-		logger: trace.NewLogger(),
+		name:      name,
+		codec:     c,
+		logger:    logger,
+		receivers: &Server{name: name, logger: logger},
 	}
 	go client.reading()
 	return client
@@ -113,19 +200,49 @@ func (c *Client) IsAvailable() bool {
 	return atomic.LoadInt32(&c.unavailable) == 0
 }
 
+// Pending 返回尚未完成的调用数
+func (c *Client) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.npending)
+}
+
+// Active 返回尚未完成的调用数与打开的流数之和, 为0时连接可以安全关闭
+func (c *Client) Active() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return int(c.npending) + len(c.streams)
+}
+
+func (c *Client) removeCall(sequence uint64) (*Call, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	value, ok := c.pending.Load(sequence)
+	if !ok {
+		return nil, false
+	}
+	c.pending.Delete(sequence)
+	c.npending--
+	return value.(*Call), true
+}
+
 func (c *Client) readResponse() (keepReading bool, err error) {
 	var resp codec.ResponseHeader
 	if err = c.codec.ReadResponseHeader(&resp); err != nil {
 		return false, err
 	}
+	if resp.Stream != 0 {
+		return true, c.readStreamFrame(&resp)
+	}
+	if resp.Reverse {
+		return true, c.serveCallback(&resp)
+	}
 
-	value, ok := c.pending.Load(resp.Sequence)
+	call, ok := c.removeCall(resp.Sequence)
 	if !ok {
 		c.codec.ReadResponseBody(nil)
 		return true, fmt.Errorf("sequence(%d) not found", resp.Sequence)
 	}
-	c.pending.Delete(resp.Sequence)
-	call := value.(*Call)
 
 	if resp.Error.Code != 0 {
 		err = c.codec.ReadResponseBody(nil)
@@ -154,22 +271,37 @@ func (c *Client) reading() {
 	} else {
 		err = ErrUnavailable
 	}
+	var calls []*Call
+	c.mu.Lock()
 	c.pending.Range(func(key, value interface{}) bool {
-		call := value.(*Call)
-		call.Error = err
-		call.done()
+		c.pending.Delete(key)
+		c.npending--
+		calls = append(calls, value.(*Call))
 		return true
 	})
+	c.mu.Unlock()
+	for _, call := range calls {
+		call.Error = err
+		call.done()
+	}
+	c.closeStreams(err)
 
 	log.Debugf("client quit reading: %v", err)
 }
 
 func (c *Client) send(call *Call) (err error) {
+	if err = c.sign(&call.Header); err != nil {
+		return err
+	}
+	c.mu.Lock()
 	if _, loaded := c.pending.LoadOrStore(call.Header.Sequence, call); loaded {
+		c.mu.Unlock()
 		return fmt.Errorf("sequence(%d) duplicate", call.Header.Sequence)
 	}
+	c.npending++
+	c.mu.Unlock()
 	if err = call.send(c.codec); err != nil {
-		c.pending.Delete(call.Header.Sequence)
+		c.removeCall(call.Header.Sequence)
 		return err
 	}
 	return nil
@@ -193,6 +325,8 @@ func (c *Client) Go(ctx context.Context, classMethod string, args interface{}, r
 
 	sequence := atomic.AddUint64(&c.sequence, 1)
 	verbose, _ := ParseVerbose(ctx)
+	subset, _ := ParseSubset(ctx)
+	hook, _ := parseCallDone(ctx)
 	traceID, ok := ParseTraceID(ctx)
 	if !ok {
 		traceID = uuid.New().String()
@@ -209,7 +343,8 @@ func (c *Client) Go(ctx context.Context, classMethod string, args interface{}, r
 		Args:  args,
 		Reply: reply,
 		Done:  done,
-		trace: c.logger.NewTrace(false, verbose, traceID, c.name, "", "", "", classMethod),
+		trace: c.logger.NewSubsetTrace(false, verbose, traceID, c.name, "", "", "", classMethod, subset),
+		hook:  hook,
 	}
 	if err := c.send(call); err != nil {
 		return nil, err
@@ -218,9 +353,7 @@ func (c *Client) Go(ctx context.Context, classMethod string, args interface{}, r
 	// 超时处理
 	if timeout > 0 {
 		time.AfterFunc(timeout, func() {
-			if value, ok := c.pending.Load(sequence); ok {
-				c.pending.Delete(sequence)
-				call := value.(*Call)
+			if call, ok := c.removeCall(sequence); ok {
 				call.Error = ErrTimeout
 				call.done()
 			}
@@ -230,6 +363,40 @@ func (c *Client) Go(ctx context.Context, classMethod string, args interface{}, r
 	return call, nil
 }
 
+// Notify 单向调用, 请求写入连接后即返回, 服务端不写应答, 返回的错误只包含发送失败
+func (c *Client) Notify(ctx context.Context, classMethod string, args interface{}) error {
+	if c.IsShutdown() {
+		return ErrShutdown
+	}
+	if !c.IsAvailable() {
+		return ErrUnavailable
+	}
+
+	sequence := atomic.AddUint64(&c.sequence, 1)
+	verbose, _ := ParseVerbose(ctx)
+	subset, _ := ParseSubset(ctx)
+	traceID, ok := ParseTraceID(ctx)
+	if !ok {
+		traceID = uuid.New().String()
+	}
+	header := codec.RequestHeader{
+		ClassMethod: classMethod,
+		Sequence:    sequence,
+		ClientName:  c.name,
+		TraceID:     traceID,
+		Verbose:     verbose,
+		OneWay:      true,
+	}
+	if err := c.sign(&header); err != nil {
+		return err
+	}
+	tr := c.logger.NewSubsetTrace(false, verbose, traceID, c.name, "", "", "", classMethod, subset)
+	err := c.codec.WriteRequest(&header, args)
+	tr.Request(args)
+	tr.Response(err, nil)
+	return err
+}
+
 func (c *Client) Call(ctx context.Context, classMethod string, args interface{}, reply interface{}, timeout time.Duration) error {
 	call, err := c.Go(ctx, classMethod, args, reply, timeout, make(chan *Call, 1))
 	if err != nil {
@@ -6,6 +6,8 @@ import (
 	"io"
 	"net"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 
 	"github.com/ironzhang/zerone/rpc/codec"
@@ -92,6 +94,34 @@ func TestClientReadResponseCorrect(t *testing.T) {
 	}
 }
 
+func TestClientRemoveCall(t *testing.T) {
+	client := Client{codec: &testClientCodec{}}
+	call := &Call{Done: make(chan *Call, 1)}
+	if err := client.send(call); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	// 并发删除同一个调用, 只有一次成功
+	var wg sync.WaitGroup
+	var removed int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, ok := client.removeCall(call.Header.Sequence); ok {
+				atomic.AddInt32(&removed, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if got, want := removed, int32(1); got != want {
+		t.Errorf("removed: got %v, want %v", got, want)
+	}
+	if got, want := client.Pending(), 0; got != want {
+		t.Errorf("pending: got %v, want %v", got, want)
+	}
+}
+
 func TestClientReadResponseError(t *testing.T) {
 	tests := []struct {
 		headerErr error
@@ -1,11 +1,33 @@
 package codec
 
+// Credential 请求的认证信息
+type Credential struct {
+	Type      string // 认证类型, 如: token, hmac
+	Principal string // 声明的身份, 如: HMAC签名的密钥ID
+	Timestamp int64  // 签名时间, unix时间戳(秒)
+	Token     string // token或签名
+}
+
+// 流帧类型常量定义, 请求和应答的Stream为0时表示普通调用
+const (
+	StreamOpen   = 1 // 打开流, 由客户端发送
+	StreamData   = 2 // 流消息
+	StreamClose  = 3 // 关闭发送方向, 由服务端发送时表示流结束, 应答的Error为流的结果
+	StreamCancel = 4 // 取消流, 由客户端发送
+	StreamAck    = 5 // 流量控制, 消息体为接收方已处理的消息数
+)
+
 type RequestHeader struct {
-	ClassMethod string // 类方法名, 格式: class.method
-	Sequence    uint64 // 序号
-	ClientName  string // 客户端名称
-	TraceID     string // TraceID
-	Verbose     int    // 日志详情等级
+	ClassMethod string     // 类方法名, 格式: class.method
+	Sequence    uint64     // 序号
+	ClientName  string     // 客户端名称
+	TraceID     string     // TraceID
+	Verbose     int        // 日志详情等级
+	Credential  Credential // 认证信息
+	Stream      int        // 流帧类型
+	OneWay      bool       // 单向调用, 服务端不写应答
+	Reverse     bool       // 反向调用的应答, 由客户端发送
+	Error       Error      // 反向调用应答的错误
 }
 
 type Error struct {
@@ -18,7 +40,11 @@ type Error struct {
 type ResponseHeader struct {
 	ClassMethod string // 类方法名, 格式: class.method
 	Sequence    uint64 // 序号
+	Stream      int    // 流帧类型
 	Error       Error  // 错误
+	Reverse     bool   // 反向调用的请求, 由服务端发送
+	TraceID     string // 反向调用的TraceID
+	Verbose     int    // 反向调用的日志详情等级
 }
 
 type ClientCodec interface {
@@ -238,6 +238,17 @@ func TestWriteReadRequest(t *testing.T) {
 			x: nil,
 			y: nil,
 		},
+		{
+			h: codec.RequestHeader{
+				ClassMethod: "Notify",
+				Sequence:    4,
+				TraceID:     "4",
+				ClientName:  "client-4",
+				OneWay:      true,
+			},
+			x: &s1,
+			y: &s2,
+		},
 	}
 	for i, tt := range tests {
 		go func(h *codec.RequestHeader, x interface{}) {
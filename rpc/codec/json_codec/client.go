@@ -35,6 +35,17 @@ func (c *ClientCodec) WriteRequest(h *codec.RequestHeader, x interface{}) error
 	c.req.TraceID = h.TraceID
 	c.req.ClientName = h.ClientName
 	c.req.Verbose = h.Verbose
+	c.req.Stream = h.Stream
+	c.req.OneWay = h.OneWay
+	c.req.Reverse = h.Reverse
+	c.req.Error = nil
+	if h.Error.Code != 0 {
+		c.req.Error = &h.Error
+	}
+	c.req.Credential = nil
+	if h.Credential.Type != "" {
+		c.req.Credential = &h.Credential
+	}
 	c.req.Body = x
 	return c.enc.Encode(&c.req)
 }
@@ -42,10 +53,14 @@ func (c *ClientCodec) WriteRequest(h *codec.RequestHeader, x interface{}) error
 func (c *ClientCodec) reset() {
 	c.resp.ClassMethod = ""
 	c.resp.Sequence = 0
+	c.resp.Stream = 0
 	c.resp.Code = 0
 	c.resp.Cause = ""
 	c.resp.Desc = ""
 	c.resp.ServerName = ""
+	c.resp.Reverse = false
+	c.resp.TraceID = ""
+	c.resp.Verbose = 0
 	c.resp.Body = nil
 }
 
@@ -58,10 +73,14 @@ func (c *ClientCodec) ReadResponseHeader(h *codec.ResponseHeader) error {
 
 	h.ClassMethod = c.resp.ClassMethod
 	h.Sequence = c.resp.Sequence
+	h.Stream = c.resp.Stream
 	h.Error.Code = c.resp.Code
 	h.Error.Cause = c.resp.Cause
 	h.Error.Desc = c.resp.Desc
 	h.Error.ServerName = c.resp.ServerName
+	h.Reverse = c.resp.Reverse
+	h.TraceID = c.resp.TraceID
+	h.Verbose = c.resp.Verbose
 	return nil
 }
 
@@ -1,41 +1,63 @@
 package json_codec
 
-import "encoding/json"
+import (
+	"encoding/json"
+
+	"github.com/ironzhang/zerone/rpc/codec"
+)
 
 type clientRequest struct {
-	ClassMethod string      `json:"ClassMethod"`
-	Sequence    uint64      `json:"Sequence"`
-	TraceID     string      `json:"TraceID"`
-	ClientName  string      `json:"ClientName"`
-	Verbose     int         `json:"Verbose,omitempty"`
-	Body        interface{} `json:"Body,omitempty"`
+	ClassMethod string            `json:"ClassMethod"`
+	Sequence    uint64            `json:"Sequence"`
+	TraceID     string            `json:"TraceID"`
+	ClientName  string            `json:"ClientName"`
+	Verbose     int               `json:"Verbose,omitempty"`
+	Credential  *codec.Credential `json:"Credential,omitempty"`
+	Stream      int               `json:"Stream,omitempty"`
+	OneWay      bool              `json:"OneWay,omitempty"`
+	Reverse     bool              `json:"Reverse,omitempty"`
+	Error       *codec.Error      `json:"Error,omitempty"`
+	Body        interface{}       `json:"Body,omitempty"`
 }
 
 type clientResponse struct {
 	ClassMethod string          `json:"ClassMethod"`
 	Sequence    uint64          `json:"Sequence"`
+	Stream      int             `json:"Stream,omitempty"`
 	Code        int             `json:"Code"`
 	Desc        string          `json:"Desc,omitempty"`
 	Cause       string          `json:"Cause,omitempty"`
 	ServerName  string          `json:"ServerName,omitempty"`
+	Reverse     bool            `json:"Reverse,omitempty"`
+	TraceID     string          `json:"TraceID,omitempty"`
+	Verbose     int             `json:"Verbose,omitempty"`
 	Body        json.RawMessage `json:"Body,omitempty"`
 }
 
 type serverRequest struct {
-	ClassMethod string          `json:"ClassMethod"`
-	Sequence    uint64          `json:"Sequence"`
-	TraceID     string          `json:"TraceID"`
-	ClientName  string          `json:"ClientName"`
-	Verbose     int             `json:"Verbose,omitempty"`
-	Body        json.RawMessage `json:"Body,omitempty"`
+	ClassMethod string            `json:"ClassMethod"`
+	Sequence    uint64            `json:"Sequence"`
+	TraceID     string            `json:"TraceID"`
+	ClientName  string            `json:"ClientName"`
+	Verbose     int               `json:"Verbose,omitempty"`
+	Credential  *codec.Credential `json:"Credential,omitempty"`
+	Stream      int               `json:"Stream,omitempty"`
+	OneWay      bool              `json:"OneWay,omitempty"`
+	Reverse     bool              `json:"Reverse,omitempty"`
+	Error       *codec.Error      `json:"Error,omitempty"`
+	Body        json.RawMessage   `json:"Body,omitempty"`
 }
 
 type serverResponse struct {
 	ClassMethod string      `json:"ClassMethod"`
 	Sequence    uint64      `json:"Sequence"`
+	Stream      int         `json:"Stream,omitempty"`
 	Code        int         `json:"Code"`
 	Desc        string      `json:"Desc,omitempty"`
 	Cause       string      `json:"Cause,omitempty"`
 	ServerName  string      `json:"ServerName,omitempty"`
+	Reverse     bool        `json:"Reverse,omitempty"`
+	TraceID     string      `json:"TraceID,omitempty"`
+	Verbose     int         `json:"Verbose,omitempty"`
 	Body        interface{} `json:"Body,omitempty"`
 }
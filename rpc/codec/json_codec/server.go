@@ -33,6 +33,11 @@ func (c *ServerCodec) reset() {
 	c.req.TraceID = ""
 	c.req.ClientName = ""
 	c.req.Verbose = 0
+	c.req.Stream = 0
+	c.req.OneWay = false
+	c.req.Reverse = false
+	c.req.Error = nil
+	c.req.Credential = nil
 	c.req.Body = nil
 }
 
@@ -48,6 +53,17 @@ func (c *ServerCodec) ReadRequestHeader(h *codec.RequestHeader) error {
 	h.TraceID = c.req.TraceID
 	h.ClientName = c.req.ClientName
 	h.Verbose = c.req.Verbose
+	h.Stream = c.req.Stream
+	h.OneWay = c.req.OneWay
+	h.Reverse = c.req.Reverse
+	h.Error = codec.Error{}
+	if c.req.Error != nil {
+		h.Error = *c.req.Error
+	}
+	h.Credential = codec.Credential{}
+	if c.req.Credential != nil {
+		h.Credential = *c.req.Credential
+	}
 	return nil
 }
 
@@ -63,10 +79,14 @@ func (c *ServerCodec) WriteResponse(h *codec.ResponseHeader, x interface{}) erro
 	defer c.mu.Unlock()
 	c.resp.ClassMethod = h.ClassMethod
 	c.resp.Sequence = h.Sequence
+	c.resp.Stream = h.Stream
 	c.resp.Code = h.Error.Code
 	c.resp.Cause = h.Error.Cause
 	c.resp.Desc = h.Error.Desc
 	c.resp.ServerName = h.Error.ServerName
+	c.resp.Reverse = h.Reverse
+	c.resp.TraceID = h.TraceID
+	c.resp.Verbose = h.Verbose
 	c.resp.Body = x
 	return c.enc.Encode(&c.resp)
 }
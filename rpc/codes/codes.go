@@ -13,6 +13,9 @@ const (
 	InvalidHeader   Code = -101
 	InvalidRequest  Code = -102
 	InvalidResponse Code = -103
+
+	Unauthenticated  Code = -201
+	PermissionDenied Code = -202
 )
 
 var codes = map[Code]string{}
@@ -39,4 +42,6 @@ func init() {
 	Register(InvalidHeader, "invalid rpc header")
 	Register(InvalidRequest, "invalid rpc request")
 	Register(InvalidResponse, "invalid rpc response")
+	Register(Unauthenticated, "unauthenticated")
+	Register(PermissionDenied, "permission denied")
 }
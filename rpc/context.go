@@ -1,6 +1,11 @@
 package rpc
 
-import "context"
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+)
 
 type keyTraceID struct{}
 
@@ -29,3 +34,99 @@ func ParseVerbose(ctx context.Context) (int, bool) {
 	}
 	return 0, false
 }
+
+type keySubset struct{}
+
+// WithSubset 记录本次调用所选的路由子集, 该子集会输出到trace日志中
+func WithSubset(ctx context.Context, subset string) context.Context {
+	return context.WithValue(ctx, keySubset{}, subset)
+}
+
+func ParseSubset(ctx context.Context) (string, bool) {
+	value := ctx.Value(keySubset{})
+	if subset, ok := value.(string); ok {
+		return subset, true
+	}
+	return "", false
+}
+
+type keyCallDone struct{}
+
+// WithCallDone 设置调用完成时的回调函数, 回调在调用结果发送到Done之前执行
+func WithCallDone(ctx context.Context, f func(*Call)) context.Context {
+	if prev, ok := parseCallDone(ctx); ok {
+		next := f
+		f = func(call *Call) {
+			prev(call)
+			next(call)
+		}
+	}
+	return context.WithValue(ctx, keyCallDone{}, f)
+}
+
+func parseCallDone(ctx context.Context) (func(*Call), bool) {
+	value := ctx.Value(keyCallDone{})
+	if f, ok := value.(func(*Call)); ok {
+		return f, true
+	}
+	return nil, false
+}
+
+// Peer 连接对端的信息
+type Peer struct {
+	Addr net.Addr             // 对端地址
+	TLS  *tls.ConnectionState // TLS连接状态, 非TLS连接时为nil
+}
+
+// Certificate 返回对端的证书, 对端未提供证书时返回nil
+func (p *Peer) Certificate() *x509.Certificate {
+	if p.TLS == nil || len(p.TLS.PeerCertificates) <= 0 {
+		return nil
+	}
+	return p.TLS.PeerCertificates[0]
+}
+
+// Identity 返回对端证书标识的身份, 优先使用CommonName, 其次为第一个DNS名称, 对端未提供证书时返回空字符串
+func (p *Peer) Identity() string {
+	cert := p.Certificate()
+	if cert == nil {
+		return ""
+	}
+	if cert.Subject.CommonName != "" {
+		return cert.Subject.CommonName
+	}
+	if len(cert.DNSNames) > 0 {
+		return cert.DNSNames[0]
+	}
+	return ""
+}
+
+type keyPeer struct{}
+
+// WithPeer 记录连接对端的信息, rpc.Server会在调用方法前设置
+func WithPeer(ctx context.Context, peer *Peer) context.Context {
+	return context.WithValue(ctx, keyPeer{}, peer)
+}
+
+func ParsePeer(ctx context.Context) (*Peer, bool) {
+	value := ctx.Value(keyPeer{})
+	if peer, ok := value.(*Peer); ok {
+		return peer, true
+	}
+	return nil, false
+}
+
+type keyPrincipal struct{}
+
+// WithPrincipal 记录认证后的身份
+func WithPrincipal(ctx context.Context, principal string) context.Context {
+	return context.WithValue(ctx, keyPrincipal{}, principal)
+}
+
+func ParsePrincipal(ctx context.Context) (string, bool) {
+	value := ctx.Value(keyPrincipal{})
+	if principal, ok := value.(string); ok {
+		return principal, true
+	}
+	return "", false
+}
@@ -0,0 +1,27 @@
+package rpc
+
+import (
+	"context"
+	"reflect"
+)
+
+// HealthCheckMethod rpc.Server自动提供的健康检查方法, 内置类名以rpc.为前缀, 不与用户注册的类冲突
+const HealthCheckMethod = "rpc.Health.Check"
+
+// HealthServing 健康检查成功时的应答
+const HealthServing = "SERVING"
+
+type health struct{}
+
+func (health) Check(ctx context.Context, args interface{}, reply *string) error {
+	*reply = HealthServing
+	return nil
+}
+
+func (s *Server) registerHealth() {
+	c, err := parseClass("rpc.Health", reflect.ValueOf(health{}))
+	if err != nil {
+		panic(err)
+	}
+	s.classMap.Store(c.name, c)
+}
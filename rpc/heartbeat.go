@@ -0,0 +1,113 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"time"
+
+	log "github.com/ironzhang/tlog"
+	"github.com/ironzhang/zerone/rpc/codec"
+)
+
+// HeartbeatMethod rpc.Server自动提供的心跳方法, 客户端定期发送ping以探测半开连接
+const HeartbeatMethod = "rpc.Heartbeat.Ping"
+
+// HeartbeatOptions 客户端心跳选项
+type HeartbeatOptions struct {
+	Interval time.Duration // 发送ping的间隔, 为0时不发送
+	Timeout  time.Duration // 等待pong的超时时间, 默认为Interval
+}
+
+type heartbeat struct{}
+
+func (heartbeat) Ping(ctx context.Context, args interface{}, reply interface{}) error {
+	return nil
+}
+
+func (s *Server) registerHeartbeat() {
+	c, err := parseClass("rpc.Heartbeat", reflect.ValueOf(heartbeat{}))
+	if err != nil {
+		panic(err)
+	}
+	s.classMap.Store(c.name, c)
+}
+
+// StartHeartbeat 启动心跳, 超时未收到pong时将客户端标记为不可用, 并以ErrUnavailable结束所有未完成的调用
+func (c *Client) StartHeartbeat(opts HeartbeatOptions) {
+	if opts.Interval <= 0 {
+		return
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = opts.Interval
+	}
+	go c.heartbeating(opts)
+}
+
+func (c *Client) heartbeating(opts HeartbeatOptions) {
+	t := time.NewTicker(opts.Interval)
+	defer t.Stop()
+
+	for range t.C {
+		if c.IsShutdown() || !c.IsAvailable() {
+			return
+		}
+		if err := c.ping(opts.Timeout); err != nil {
+			log.Warnf("client(%s) heartbeat: %v, mark unavailable", c.name, err)
+			c.markUnavailable()
+			return
+		}
+	}
+}
+
+// ping 发送ping并等待pong, 服务端返回的任何应答都视为pong
+func (c *Client) ping(timeout time.Duration) error {
+	call := &Call{
+		Header: codec.RequestHeader{
+			ClassMethod: HeartbeatMethod,
+			Sequence:    atomic.AddUint64(&c.sequence, 1),
+			ClientName:  c.name,
+		},
+		Done: make(chan *Call, 1),
+	}
+	if err := c.send(call); err != nil {
+		return err
+	}
+
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	select {
+	case <-call.Done:
+		if call.Error == ErrUnavailable || call.Error == ErrShutdown {
+			return call.Error
+		}
+		return nil
+	case <-t.C:
+		c.removeCall(call.Header.Sequence)
+		return ErrTimeout
+	}
+}
+
+// markUnavailable 将客户端标记为不可用并关闭连接, reading退出时以ErrUnavailable结束未完成的调用
+func (c *Client) markUnavailable() {
+	atomic.StoreInt32(&c.unavailable, 1)
+	c.codec.Close()
+}
+
+// SetIdleTimeout 设置连接的空闲超时时间, 连接超过该时间没有收到任何数据时关闭, 为0时不关闭
+func (s *Server) SetIdleTimeout(d time.Duration) {
+	atomic.StoreInt64(&s.idleTimeout, int64(d))
+}
+
+type idleConn struct {
+	net.Conn
+	timeout *int64
+}
+
+func (c idleConn) Read(p []byte) (int, error) {
+	if d := time.Duration(atomic.LoadInt64(c.timeout)); d > 0 {
+		c.SetReadDeadline(time.Now().Add(d))
+	}
+	return c.Conn.Read(p)
+}
@@ -0,0 +1,129 @@
+// Package memconn 提供进程内的内存连接, 用于在不占用网络端口的情况下运行完整的服务端和客户端.
+//
+// 服务端通过Listen在一个名称上监听, 客户端以网络类型Network和该名称作为地址建立连接,
+// rpc.DialWithOptions及zserver均支持该网络类型, 因此路由表中可以直接引用内存地址:
+//
+//	{"Name": "0", "Net": "mem", "Addr": "arith"}
+package memconn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+)
+
+// Network 内存连接的网络类型
+const Network = "mem"
+
+var (
+	ErrClosed  = errors.New("memconn: listener closed")
+	ErrRefused = errors.New("memconn: connection refused")
+)
+
+var (
+	mu        sync.Mutex
+	listeners = make(map[string]*Listener)
+)
+
+// Addr 内存地址
+type Addr string
+
+func (a Addr) Network() string {
+	return Network
+}
+
+func (a Addr) String() string {
+	return string(a)
+}
+
+var _ net.Listener = &Listener{}
+
+// Listener 内存监听器
+type Listener struct {
+	addr  Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+// Listen 在进程内的address上监听, address已被占用时返回错误
+func Listen(address string) (*Listener, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := listeners[address]; ok {
+		return nil, fmt.Errorf("memconn: address %s already in use", address)
+	}
+	ln := &Listener{
+		addr:  Addr(address),
+		conns: make(chan net.Conn),
+		done:  make(chan struct{}),
+	}
+	listeners[address] = ln
+	return ln, nil
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.done:
+		return nil, ErrClosed
+	}
+}
+
+func (l *Listener) Close() error {
+	l.once.Do(func() {
+		mu.Lock()
+		if listeners[string(l.addr)] == l {
+			delete(listeners, string(l.addr))
+		}
+		mu.Unlock()
+		close(l.done)
+	})
+	return nil
+}
+
+func (l *Listener) Addr() net.Addr {
+	return l.addr
+}
+
+// Dial 连接进程内的address, 没有监听者时返回ErrRefused
+func Dial(ctx context.Context, address string) (net.Conn, error) {
+	mu.Lock()
+	ln, ok := listeners[address]
+	mu.Unlock()
+	if !ok {
+		return nil, ErrRefused
+	}
+
+	client, server := net.Pipe()
+	select {
+	case ln.conns <- &conn{Conn: server, local: ln.addr, remote: Addr("client")}:
+		return &conn{Conn: client, local: Addr("client"), remote: ln.addr}, nil
+	case <-ln.done:
+		client.Close()
+		server.Close()
+		return nil, ErrRefused
+	case <-ctx.Done():
+		client.Close()
+		server.Close()
+		return nil, ctx.Err()
+	}
+}
+
+type conn struct {
+	net.Conn
+	local  net.Addr
+	remote net.Addr
+}
+
+func (c *conn) LocalAddr() net.Addr {
+	return c.local
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remote
+}
@@ -0,0 +1,77 @@
+package memconn
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestListenDial(t *testing.T) {
+	ln, err := Listen("TestListenDial")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if _, err = Listen("TestListenDial"); err == nil {
+		t.Errorf("listen on the same address should fail")
+	}
+
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		io.Copy(c, c)
+	}()
+
+	c, err := Dial(context.Background(), "TestListenDial")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	if got, want := c.RemoteAddr().String(), "TestListenDial"; got != want {
+		t.Errorf("remote addr: got %v, want %v", got, want)
+	}
+	if got, want := c.RemoteAddr().Network(), Network; got != want {
+		t.Errorf("network: got %v, want %v", got, want)
+	}
+
+	if _, err = c.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 5)
+	if _, err = io.ReadFull(c, buf); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if got, want := string(buf), "hello"; got != want {
+		t.Errorf("read: got %q, want %q", got, want)
+	}
+
+	ln.Close()
+	if _, err = ln.Accept(); err != ErrClosed {
+		t.Errorf("accept: got %v, want %v", err, ErrClosed)
+	}
+	if _, err = Dial(context.Background(), "TestListenDial"); err != ErrRefused {
+		t.Errorf("dial: got %v, want %v", err, ErrRefused)
+	}
+	if ln, err = Listen("TestListenDial"); err != nil {
+		t.Fatalf("listen after close: %v", err)
+	}
+	ln.Close()
+}
+
+func TestDialTimeout(t *testing.T) {
+	ln, err := Listen("TestDialTimeout")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// 没有调用Accept, Dial在ctx超时后返回
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err = Dial(ctx, "TestDialTimeout"); err != context.DeadlineExceeded {
+		t.Errorf("dial: got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
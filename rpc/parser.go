@@ -15,6 +15,7 @@ var (
 	typeOfError        = reflect.TypeOf((*error)(nil)).Elem()
 	typeOfContext      = reflect.TypeOf((*context.Context)(nil)).Elem()
 	typeOfNilInterface = reflect.TypeOf((*interface{})(nil)).Elem()
+	typeOfStream       = reflect.TypeOf((*Stream)(nil)).Elem()
 )
 
 // Is this an exported - upper case - name?
@@ -79,9 +80,22 @@ type method struct {
 	method reflect.Method
 	args   reflect.Type
 	reply  reflect.Type
+	stream bool // 流方法
+}
+
+// Stream method needs three ins: receiver, context.Context, rpc.Stream.
+func isStreamMethod(m reflect.Method) bool {
+	mtype := m.Type
+	return mtype.NumIn() == 3 && mtype.In(1).Implements(typeOfContext) && mtype.In(2) == typeOfStream
 }
 
 func parseMethod(m reflect.Method) (*method, error) {
+	if isStreamMethod(m) {
+		if err := checkOuts(m); err != nil {
+			return nil, err
+		}
+		return &method{method: m, stream: true}, nil
+	}
 	_, _, args, reply, err := checkIns(m)
 	if err != nil {
 		return nil, err
@@ -3,6 +3,7 @@ package rpc_test
 import (
 	"context"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net"
 	"reflect"
@@ -50,6 +51,13 @@ func (t *Time) Sleep(ctx context.Context, args int, reply interface{}) error {
 	return nil
 }
 
+type Notifier chan int
+
+func (n Notifier) Notify(ctx context.Context, args int, reply interface{}) error {
+	n <- args
+	return nil
+}
+
 func ServeRPC(network, address string) {
 	ln, err := net.Listen(network, address)
 	if err != nil {
@@ -312,3 +320,218 @@ func BenchmarkNClientsCall(b *testing.B) {
 		}
 	})
 }
+
+func TestNotify(t *testing.T) {
+	notifier := make(Notifier, 10)
+	svr := rpc.NewServer("TestNotify")
+	if err := svr.Register(notifier); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := svr.Register(new(Arith)); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svr.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	cc, sc := net.Pipe()
+	go svr.ServeConn(sc)
+	c := rpc.NewClient("TestNotify", cc)
+	defer c.Close()
+	c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+
+	for i := 0; i < 3; i++ {
+		if err := c.Notify(context.Background(), "Notifier.Notify", i); err != nil {
+			t.Fatalf("notify: %v", err)
+		}
+		if got, want := c.Pending(), 0; got != want {
+			t.Errorf("%d: pending: got %v, want %v", i, got, want)
+		}
+		select {
+		case n := <-notifier:
+			if n != i {
+				t.Errorf("%d: got %v, want %v", i, n, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%d: notify is not received", i)
+		}
+	}
+
+	// 单向调用出错时服务端也不写应答, 后续的调用不受影响
+	if err := c.Notify(context.Background(), "Notifier.NotFound", 0); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+	var reply int
+	if err := c.Call(context.Background(), "Arith.Multiply", Args{2, 3}, &reply, time.Second); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if reply != 6 {
+		t.Errorf("reply: got %v, want %v", reply, 6)
+	}
+}
+
+func TestHealthCheck(t *testing.T) {
+	c, err := rpc.Dial("TestHealthCheck", "tcp", "localhost:2000")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	defer c.Close()
+
+	var status string
+	if err = c.Call(context.Background(), rpc.HealthCheckMethod, nil, &status, 0); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got, want := status, rpc.HealthServing; got != want {
+		t.Fatalf("status: got %v, want %v", got, want)
+	}
+}
+
+func TestCallDoneHook(t *testing.T) {
+	c, err := rpc.Dial("TestCallDoneHook", "tcp", "localhost:2000")
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	defer c.Close()
+
+	var hooks []string
+	ctx := rpc.WithCallDone(context.Background(), func(call *rpc.Call) {
+		hooks = append(hooks, "first")
+	})
+	ctx = rpc.WithCallDone(ctx, func(call *rpc.Call) {
+		hooks = append(hooks, call.Header.ClassMethod)
+	})
+
+	var reply int
+	if err = c.Call(ctx, "Arith.Multiply", Args{A: 2, B: 3}, &reply, 0); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got, want := hooks, []string{"first", "Arith.Multiply"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("hooks: got %v, want %v", got, want)
+	}
+}
+
+func TestDialWithOptions(t *testing.T) {
+	var dialed string
+	opts := rpc.DialOptions{
+		Timeout: time.Second,
+		Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if _, ok := ctx.Deadline(); !ok {
+				t.Errorf("dialer context has no deadline")
+			}
+			dialed = address
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+	c, err := rpc.DialWithOptions("TestDialWithOptions", "tcp", "localhost:2000", opts)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+
+	if got, want := dialed, "localhost:2000"; got != want {
+		t.Errorf("dialed: got %v, want %v", got, want)
+	}
+	var reply int
+	if err = c.Call(context.Background(), "Arith.Multiply", Args{A: 2, B: 3}, &reply, 0); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got, want := reply, 6; got != want {
+		t.Errorf("reply: got %v, want %v", got, want)
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	opts := rpc.DialOptions{
+		Timeout: 50 * time.Millisecond,
+		Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+	start := time.Now()
+	if _, err := rpc.DialWithOptions("TestDialTimeout", "tcp", "localhost:2000", opts); err == nil {
+		t.Fatalf("dial: expect error")
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("dial timeout too long: %v", d)
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	opts := rpc.DialOptions{Heartbeat: rpc.HeartbeatOptions{Interval: 10 * time.Millisecond}}
+	c, err := rpc.DialWithOptions("TestHeartbeat", "tcp", "localhost:2000", opts)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	if !c.IsAvailable() {
+		t.Errorf("client is unavailable")
+	}
+}
+
+func TestHeartbeatMissedPong(t *testing.T) {
+	// 只接受连接而从不应答的服务端
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	opts := rpc.DialOptions{Heartbeat: rpc.HeartbeatOptions{Interval: 20 * time.Millisecond}}
+	c, err := rpc.DialWithOptions("TestHeartbeatMissedPong", "tcp", ln.Addr().String(), opts)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer c.Close()
+	c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+
+	call, err := c.Go(context.Background(), "Arith.Multiply", Args{A: 2, B: 3}, new(int), 0, nil)
+	if err != nil {
+		t.Fatalf("go: %v", err)
+	}
+	select {
+	case <-call.Done:
+		if got, want := call.Error, rpc.ErrUnavailable; got != want {
+			t.Errorf("error: got %v, want %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("pending call is not failed")
+	}
+	if c.IsAvailable() {
+		t.Errorf("client is available")
+	}
+}
+
+func TestServerIdleTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	svr := rpc.NewServer("TestServerIdleTimeout")
+	svr.SetIdleTimeout(50 * time.Millisecond)
+	go svr.Accept(ln)
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err = conn.Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("read: got %v, want %v", err, io.EOF)
+	}
+}
@@ -2,6 +2,7 @@ package rpc
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"net"
@@ -9,6 +10,8 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	log "github.com/ironzhang/tlog"
 	"github.com/ironzhang/zerone/rpc/codec"
@@ -18,16 +21,23 @@ import (
 )
 
 type Server struct {
-	name     string
-	logger   *trace.Logger
-	classMap sync.Map
+	idleTimeout int64
+	name        string
+	logger      *trace.Logger
+	classMap    sync.Map
+	auth        atomic.Value
+	acl         atomic.Value
 }
 
 func NewServer(name string) *Server {
-	return &Server{
+	s := &Server{
 		name:   name,
 		logger: trace.NewLogger(),
 	}
+	s.registerHealth()
+	s.registerHeartbeat()
+	s.registerBatch()
+	return s
 }
 
 func (s *Server) Name() string {
@@ -102,13 +112,16 @@ func (s *Server) lookupClassMethod(className, methodName string) (reflect.Value,
 func (s *Server) readRequest(c codec.ServerCodec) (req *codec.RequestHeader, method reflect.Method, rcvr, args, reply reflect.Value, keepReading bool, err error) {
 	var h codec.RequestHeader
 	if err = c.ReadRequestHeader(&h); err != nil {
-		if err != io.EOF && err != io.ErrUnexpectedEOF {
+		if _, ok := err.(net.Error); !ok && err != io.EOF && err != io.ErrUnexpectedEOF {
 			keepReading = true
 		}
 		return
 	}
 	req = &h
 	keepReading = true
+	if req.Stream != 0 || req.Reverse {
+		return
+	}
 
 	className, methodName, err := splitClassMethod(req.ClassMethod)
 	if err != nil {
@@ -122,8 +135,18 @@ func (s *Server) readRequest(c codec.ServerCodec) (req *codec.RequestHeader, met
 		c.ReadRequestBody(nil)
 		return
 	}
+	if meth.stream {
+		err = Errorf(codes.InvalidHeader, "method %s is a stream method", req.ClassMethod)
+		c.ReadRequestBody(nil)
+		return
+	}
 	method = meth.method
+	args, reply, err = newArgsReply(meth, c.ReadRequestBody)
+	return
+}
 
+// newArgsReply 创建方法的参数和应答, read读取请求的消息体
+func newArgsReply(meth *method, read func(interface{}) error) (args, reply reflect.Value, err error) {
 	argIsValue := false
 	if meth.args.Kind() == reflect.Ptr {
 		args = reflect.New(meth.args.Elem())
@@ -132,7 +155,7 @@ func (s *Server) readRequest(c codec.ServerCodec) (req *codec.RequestHeader, met
 		argIsValue = true
 	}
 	if !isNilInterface(meth.args) {
-		if err = c.ReadRequestBody(args.Interface()); err != nil {
+		if err = read(args.Interface()); err != nil {
 			err = NewError(codes.InvalidRequest, err)
 			return
 		}
@@ -157,36 +180,49 @@ func (s *Server) readRequest(c codec.ServerCodec) (req *codec.RequestHeader, met
 }
 
 func (s *Server) writeResponse(c codec.ServerCodec, req *codec.RequestHeader, reply interface{}, err error) error {
+	return s.writeFrame(c, req, 0, reply, err)
+}
+
+// writeFrame 写应答, frame为流帧类型, 普通调用为0
+func (s *Server) writeFrame(c codec.ServerCodec, req *codec.RequestHeader, frame int, reply interface{}, err error) error {
 	var resp codec.ResponseHeader
 	resp.ClassMethod = req.ClassMethod
 	resp.Sequence = req.Sequence
-	if err != nil {
-		code := codes.Unknown
-		if e, ok := err.(ErrorCode); ok {
-			code = e.Code()
-		}
-		cause := err.Error()
-		if e, ok := err.(ErrorCause); ok {
-			if ce := e.Cause(); ce != nil {
-				cause = ce.Error()
-			}
+	resp.Stream = frame
+	resp.Error = s.responseError(err)
+	return c.WriteResponse(&resp, reply)
+}
+
+// responseError 将错误转换为应答中的错误信息
+func (s *Server) responseError(err error) codec.Error {
+	if err == nil {
+		return codec.Error{}
+	}
+	code := codes.Unknown
+	if e, ok := err.(ErrorCode); ok {
+		code = e.Code()
+	}
+	cause := err.Error()
+	if e, ok := err.(ErrorCause); ok {
+		if ce := e.Cause(); ce != nil {
+			cause = ce.Error()
 		}
-		name := s.name
-		if e, ok := err.(ErrorServer); ok {
-			if sn := e.Server(); sn != "" {
-				name = sn
-			}
+	}
+	name := s.name
+	if e, ok := err.(ErrorServer); ok {
+		if sn := e.Server(); sn != "" {
+			name = sn
 		}
-
-		resp.Error.Code = int(code)
-		resp.Error.Desc = code.String()
-		resp.Error.Cause = cause
-		resp.Error.ServerName = name
 	}
-	return c.WriteResponse(&resp, reply)
+	return codec.Error{
+		Code:       int(code),
+		Desc:       code.String(),
+		Cause:      cause,
+		ServerName: name,
+	}
 }
 
-func (s *Server) call(req *codec.RequestHeader, method reflect.Method, rcvr, args, reply reflect.Value) (err error) {
+func (s *Server) call(ctx context.Context, req *codec.RequestHeader, method reflect.Method, rcvr, args, reply reflect.Value) (err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			const size = 64 << 10
@@ -202,7 +238,6 @@ func (s *Server) call(req *codec.RequestHeader, method reflect.Method, rcvr, arg
 		}
 	}()
 
-	ctx := context.Background()
 	ctx = WithTraceID(ctx, req.TraceID)
 	ctx = WithVerbose(ctx, req.Verbose)
 	rets := method.Func.Call([]reflect.Value{rcvr, reflect.ValueOf(ctx), args, reply})
@@ -249,15 +284,33 @@ var emptyResp = struct{}{}
 func (s *Server) serveError(c codec.ServerCodec, req *codec.RequestHeader, err error) {
 	tr := s.logger.NewTrace(true, req.Verbose, req.TraceID, req.ClientName, "", s.name, "", req.ClassMethod)
 	tr.Request(nil)
-	s.writeResponse(c, req, emptyResp, err)
+	if !req.OneWay {
+		s.writeResponse(c, req, emptyResp, err)
+	}
 	tr.Response(s.rpcError(err), emptyResp)
 }
 
-func (s *Server) serveCall(c codec.ServerCodec, req *codec.RequestHeader, method reflect.Method, rcvr, args, reply reflect.Value) {
+func (s *Server) serveCall(ctx context.Context, c codec.ServerCodec, req *codec.RequestHeader, method reflect.Method, rcvr, args, reply reflect.Value) {
+	if req.ClassMethod == HeartbeatMethod {
+		s.writeResponse(c, req, reply.Interface(), nil)
+		return
+	}
+	ctx, err := s.authenticate(ctx, req)
+	if err != nil {
+		s.serveError(c, req, err)
+		return
+	}
+	if req.ClassMethod == BatchMethod {
+		s.serveBatch(ctx, c, req, args.Interface().(*BatchArgs))
+		return
+	}
 	tr := s.logger.NewTrace(true, req.Verbose, req.TraceID, req.ClientName, "", s.name, "", req.ClassMethod)
 	tr.Request(args.Interface())
-	err := s.call(req, method, rcvr, args, reply)
-	s.writeResponse(c, req, reply.Interface(), err)
+	err = s.call(ctx, req, method, rcvr, args, reply)
+	// 单向调用不写应答, 错误只记录在trace中
+	if !req.OneWay {
+		s.writeResponse(c, req, reply.Interface(), err)
+	}
 	tr.Response(s.rpcError(err), reply.Interface())
 }
 
@@ -272,12 +325,32 @@ func (s *Server) ServeRequest(c codec.ServerCodec) error {
 		}
 		return err
 	}
-	s.serveCall(c, req, method, rcvr, args, reply)
+	if req.Reverse {
+		c.ReadRequestBody(nil)
+		return fmt.Errorf("method %s: reverse response is not supported by ServeRequest", req.ClassMethod)
+	}
+	if req.Stream != 0 {
+		c.ReadRequestBody(nil)
+		err = Errorf(codes.InvalidHeader, "method %s: stream is not supported by ServeRequest", req.ClassMethod)
+		s.serveStreamError(c, req, err)
+		return err
+	}
+	s.serveCall(context.Background(), c, req, method, rcvr, args, reply)
 	return nil
 }
 
 func (s *Server) ServeCodec(c codec.ServerCodec) {
+	s.serveCodec(context.Background(), c)
+}
+
+// serveCodec 处理连接上的请求, ctx为连接级别的上下文, 如对端信息
+func (s *Server) serveCodec(ctx context.Context, c codec.ServerCodec) {
 	defer c.Close()
+	var streams serverStreams
+	defer streams.close(ErrShutdown)
+	caller := newCaller(s, c)
+	defer caller.close(ErrShutdown)
+	ctx = withCaller(ctx, caller)
 	for {
 		req, method, rcvr, args, reply, keepReading, err := s.readRequest(c)
 		if err != nil {
@@ -289,13 +362,46 @@ func (s *Server) ServeCodec(c codec.ServerCodec) {
 			}
 			continue
 		}
-		go s.serveCall(c, req, method, rcvr, args, reply)
+		if req.Stream != 0 {
+			s.serveStreamFrame(ctx, c, &streams, req)
+			continue
+		}
+		if req.Reverse {
+			if err = caller.readResponse(req); err != nil {
+				log.Debugf("read reverse response: %v", err)
+			}
+			continue
+		}
+		go s.serveCall(ctx, c, req, method, rcvr, args, reply)
 	}
 	log.Debug("server quit serve codec")
 }
 
 func (s *Server) ServeConn(rwc io.ReadWriteCloser) {
-	s.ServeCodec(json_codec.NewServerCodec(rwc))
+	ctx := context.Background()
+	if conn, ok := rwc.(net.Conn); ok {
+		peer := &Peer{Addr: conn.RemoteAddr()}
+		if tc, ok := conn.(*tls.Conn); ok {
+			if err := s.handshake(tc); err != nil {
+				log.Debugf("tls handshake with %v: %v", conn.RemoteAddr(), err)
+				conn.Close()
+				return
+			}
+			state := tc.ConnectionState()
+			peer.TLS = &state
+		}
+		ctx = WithPeer(ctx, peer)
+		rwc = idleConn{Conn: conn, timeout: &s.idleTimeout}
+	}
+	s.serveCodec(ctx, json_codec.NewServerCodec(rwc))
+}
+
+func (s *Server) handshake(tc *tls.Conn) error {
+	if d := time.Duration(atomic.LoadInt64(&s.idleTimeout)); d > 0 {
+		tc.SetDeadline(time.Now().Add(d))
+		defer tc.SetDeadline(time.Time{})
+	}
+	return tc.Handshake()
 }
 
 func (s *Server) Accept(ln net.Listener) {
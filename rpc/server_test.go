@@ -147,6 +147,26 @@ func TestServerRegisterError(t *testing.T) {
 	}
 }
 
+func TestServerRegisterBuiltinNames(t *testing.T) {
+	// 内置类使用rpc.前缀, 用户仍可注册同名的类
+	s := NewServer("Server")
+	for i, name := range []string{"Health", "Heartbeat", "Batch"} {
+		var a Arith
+		if err := s.RegisterName(name, &a); err != nil {
+			t.Errorf("%d: register %s: %v", i, name, err)
+		}
+	}
+	for i, classMethod := range []string{HealthCheckMethod, HeartbeatMethod, BatchMethod} {
+		className, methodName, err := splitClassMethod(classMethod)
+		if err != nil {
+			t.Fatalf("%d: split class method: %v", i, err)
+		}
+		if _, _, err = s.lookupClassMethod(className, methodName); err != nil {
+			t.Errorf("%d: lookup %s: %v", i, classMethod, err)
+		}
+	}
+}
+
 func TestSplitServiceMethodCorrect(t *testing.T) {
 	tests := []struct {
 		serviceMethod string
@@ -573,7 +593,7 @@ func TestServerCallCorrect(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		err := s.call(req, tt.method, reflect.ValueOf(tt.rcvr), reflect.ValueOf(tt.args), reflect.ValueOf(tt.reply))
+		err := s.call(context.Background(), req, tt.method, reflect.ValueOf(tt.rcvr), reflect.ValueOf(tt.args), reflect.ValueOf(tt.reply))
 		if err != nil {
 			t.Fatalf("serveCall: %v", err)
 		}
@@ -604,7 +624,7 @@ func TestServerCallError(t *testing.T) {
 		},
 	}
 	for _, tt := range tests {
-		err := s.call(req, tt.method, reflect.ValueOf(tt.rcvr), reflect.ValueOf(tt.args), reflect.ValueOf(tt.reply))
+		err := s.call(context.Background(), req, tt.method, reflect.ValueOf(tt.rcvr), reflect.ValueOf(tt.args), reflect.ValueOf(tt.reply))
 		if err == nil {
 			t.Fatalf("serveCall: return error is nil")
 		} else {
@@ -681,6 +701,18 @@ func TestServerServeRequest(t *testing.T) {
 			},
 			respBody: emptyResp,
 		},
+		{
+			reqHeader:  codec.RequestHeader{ClassMethod: "Arith.Add", Sequence: 1, OneWay: true},
+			reqBody:    Args{1, 2},
+			respHeader: codec.ResponseHeader{},
+			respBody:   nil,
+		},
+		{
+			reqHeader:  codec.RequestHeader{ClassMethod: "Arith", Sequence: 1, OneWay: true},
+			reqBody:    Args{1, 2},
+			respHeader: codec.ResponseHeader{},
+			respBody:   nil,
+		},
 	}
 	for i, tt := range tests {
 		codec := &testServerCodec{reqHeaderErr: tt.reqHeaderErr, reqHeader: tt.reqHeader, reqBodyErr: tt.reqBodyErr, reqBody: tt.reqBody}
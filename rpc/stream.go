@@ -0,0 +1,487 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ironzhang/pearls/uuid"
+	log "github.com/ironzhang/tlog"
+	"github.com/ironzhang/zerone/rpc/codec"
+	"github.com/ironzhang/zerone/rpc/codes"
+)
+
+// StreamWindow 流量控制窗口, 接收方未处理的消息数达到该值时发送方阻塞
+const StreamWindow = 64
+
+// ErrStreamClosed 已关闭发送方向的流不能再发送消息
+var ErrStreamClosed = errors.New("send on closed stream")
+
+// Stream 流, 消息以Sequence复用在已有的连接上. Send和Recv可以在不同的goroutine中并发调用,
+// 但不能在多个goroutine中同时调用Send或同时调用Recv.
+// 流方法的签名为: func (t *T) Method(ctx context.Context, stream rpc.Stream) error,
+// 流方法返回时流结束, 返回的错误即为客户端Recv得到的错误
+type Stream interface {
+	// Context 返回流的上下文, 流结束或被取消时取消
+	Context() context.Context
+
+	// Send 发送消息, 对端处理不及时时阻塞, 流已结束时返回io.EOF, 可以通过Recv获取结束的原因
+	Send(m interface{}) error
+
+	// Recv 接收消息, 对端关闭发送方向后返回io.EOF, 流异常结束时返回对应的错误
+	Recv(m interface{}) error
+}
+
+// ClientStream 客户端流, 读到Recv返回的错误或取消ctx后释放资源
+type ClientStream interface {
+	Stream
+
+	// CloseSend 关闭发送方向, 服务端的Recv将返回io.EOF
+	CloseSend() error
+}
+
+type stream struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	write  func(frame int, body interface{}) error
+	done   chan struct{} // 流结束后关闭
+	recvc  chan struct{}
+	sendc  chan struct{}
+
+	mu       sync.Mutex
+	queue    []json.RawMessage
+	eof      error // 对端关闭发送方向后Recv返回的错误
+	credits  int   // 可以发送的消息数
+	consumed int   // 已处理但尚未确认的消息数
+	closed   bool
+	finished bool
+}
+
+func newStream(ctx context.Context, cancel context.CancelFunc, write func(frame int, body interface{}) error) *stream {
+	return &stream{
+		ctx:     ctx,
+		cancel:  cancel,
+		write:   write,
+		done:    make(chan struct{}),
+		recvc:   make(chan struct{}, 1),
+		sendc:   make(chan struct{}, 1),
+		credits: StreamWindow,
+	}
+}
+
+func (s *stream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *stream) Send(m interface{}) error {
+	for {
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			return ErrStreamClosed
+		}
+		if s.finished {
+			s.mu.Unlock()
+			return io.EOF
+		}
+		if s.credits > 0 {
+			s.credits--
+			s.mu.Unlock()
+			return s.write(codec.StreamData, m)
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.sendc:
+		case <-s.done:
+		case <-s.ctx.Done():
+			if !s.isFinished() {
+				return s.ctx.Err()
+			}
+		}
+	}
+}
+
+func (s *stream) Recv(m interface{}) error {
+	for {
+		s.mu.Lock()
+		if len(s.queue) > 0 {
+			raw := s.queue[0]
+			s.queue = s.queue[1:]
+			s.consumed++
+			ack := 0
+			if s.consumed >= StreamWindow/2 && s.eof == nil {
+				ack, s.consumed = s.consumed, 0
+			}
+			s.mu.Unlock()
+
+			if ack > 0 {
+				s.write(codec.StreamAck, ack)
+			}
+			if m == nil {
+				return nil
+			}
+			if err := json.Unmarshal(raw, m); err != nil {
+				return NewError(codes.InvalidResponse, err)
+			}
+			return nil
+		}
+		if s.eof != nil {
+			err := s.eof
+			s.mu.Unlock()
+			return err
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.recvc:
+		case <-s.ctx.Done():
+			// 流结束时也会取消ctx, 此时需要先返回已收到的消息
+			if !s.isFinished() {
+				return s.ctx.Err()
+			}
+		}
+	}
+}
+
+func (s *stream) CloseSend() error {
+	s.mu.Lock()
+	if s.closed || s.finished {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	s.mu.Unlock()
+	return s.write(codec.StreamClose, nil)
+}
+
+func (s *stream) isFinished() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finished
+}
+
+// err 返回流结束的原因
+func (s *stream) err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.eof
+}
+
+// receive 处理对端发来的帧, read读取帧的消息体
+func (s *stream) receive(frame int, read func(interface{}) error) error {
+	switch frame {
+	case codec.StreamData:
+		var raw json.RawMessage
+		if err := read(&raw); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.queue = append(s.queue, raw)
+		s.mu.Unlock()
+		notify(s.recvc)
+	case codec.StreamAck:
+		var n int
+		if err := read(&n); err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.credits += n
+		s.mu.Unlock()
+		notify(s.sendc)
+	case codec.StreamClose:
+		read(nil)
+		s.closeRecv(io.EOF)
+	case codec.StreamCancel:
+		read(nil)
+		s.finish(context.Canceled)
+	default:
+		read(nil)
+		return fmt.Errorf("unknown stream frame %d", frame)
+	}
+	return nil
+}
+
+// closeRecv 对端关闭了发送方向, 之后Recv在读完已收到的消息后返回err
+func (s *stream) closeRecv(err error) {
+	s.mu.Lock()
+	if s.eof == nil {
+		s.eof = err
+	}
+	s.mu.Unlock()
+	notify(s.recvc)
+}
+
+// finish 结束流, 首次结束时返回true
+func (s *stream) finish(err error) bool {
+	s.mu.Lock()
+	if s.finished {
+		s.mu.Unlock()
+		return false
+	}
+	s.finished = true
+	if err == nil {
+		err = io.EOF
+	}
+	if s.eof == nil {
+		s.eof = err
+	}
+	s.mu.Unlock()
+
+	close(s.done)
+	s.cancel()
+	notify(s.recvc)
+	return true
+}
+
+func notify(c chan struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// NewStream 打开流, 取消ctx时取消流
+func (c *Client) NewStream(ctx context.Context, classMethod string) (ClientStream, error) {
+	if c.IsShutdown() {
+		return nil, ErrShutdown
+	}
+	if !c.IsAvailable() {
+		return nil, ErrUnavailable
+	}
+
+	sequence := atomic.AddUint64(&c.sequence, 1)
+	verbose, _ := ParseVerbose(ctx)
+	subset, _ := ParseSubset(ctx)
+	traceID, ok := ParseTraceID(ctx)
+	if !ok {
+		traceID = uuid.New().String()
+	}
+	header := codec.RequestHeader{
+		ClassMethod: classMethod,
+		Sequence:    sequence,
+		ClientName:  c.name,
+		TraceID:     traceID,
+		Verbose:     verbose,
+		Stream:      codec.StreamOpen,
+	}
+	if err := c.sign(&header); err != nil {
+		return nil, err
+	}
+
+	sctx, cancel := context.WithCancel(ctx)
+	st := newStream(sctx, cancel, func(frame int, body interface{}) error {
+		h := codec.RequestHeader{
+			ClassMethod: classMethod,
+			Sequence:    sequence,
+			ClientName:  c.name,
+			TraceID:     traceID,
+			Stream:      frame,
+		}
+		return c.codec.WriteRequest(&h, body)
+	})
+	c.addStream(sequence, st)
+	if err := c.codec.WriteRequest(&header, nil); err != nil {
+		c.removeStream(sequence)
+		cancel()
+		return nil, err
+	}
+
+	tr := c.logger.NewSubsetTrace(false, verbose, traceID, c.name, "", "", "", classMethod, subset)
+	tr.Request(nil)
+	go func() {
+		select {
+		case <-st.done:
+		case <-sctx.Done():
+			if st.finish(sctx.Err()) {
+				st.write(codec.StreamCancel, nil)
+			}
+		}
+		c.removeStream(sequence)
+		err := st.err()
+		if err == io.EOF {
+			err = nil
+		}
+		tr.Response(err, nil)
+	}()
+	return st, nil
+}
+
+// readStreamFrame 处理服务端发来的流帧
+func (c *Client) readStreamFrame(resp *codec.ResponseHeader) error {
+	c.mu.Lock()
+	st, ok := c.streams[resp.Sequence]
+	c.mu.Unlock()
+	if !ok {
+		c.codec.ReadResponseBody(nil)
+		return nil
+	}
+	if resp.Stream == codec.StreamClose {
+		c.codec.ReadResponseBody(nil)
+		c.removeStream(resp.Sequence)
+		err := io.EOF
+		if resp.Error.Code != 0 {
+			err = ServerErrorf(resp.Error.ServerName, codes.Code(resp.Error.Code), resp.Error.Cause)
+		}
+		st.finish(err)
+		return nil
+	}
+	return st.receive(resp.Stream, c.codec.ReadResponseBody)
+}
+
+func (c *Client) addStream(sequence uint64, st *stream) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.streams == nil {
+		c.streams = make(map[uint64]*stream)
+	}
+	c.streams[sequence] = st
+}
+
+func (c *Client) removeStream(sequence uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.streams, sequence)
+}
+
+// closeStreams 连接断开时以err结束所有的流
+func (c *Client) closeStreams(err error) {
+	c.mu.Lock()
+	streams := c.streams
+	c.streams = nil
+	c.mu.Unlock()
+	for _, st := range streams {
+		st.finish(err)
+	}
+}
+
+// serverStreams 连接上的服务端流
+type serverStreams struct {
+	mu      sync.Mutex
+	streams map[uint64]*stream
+}
+
+func (m *serverStreams) load(sequence uint64) *stream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.streams[sequence]
+}
+
+func (m *serverStreams) store(sequence uint64, st *stream) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.streams == nil {
+		m.streams = make(map[uint64]*stream)
+	}
+	m.streams[sequence] = st
+}
+
+func (m *serverStreams) delete(sequence uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.streams, sequence)
+}
+
+func (m *serverStreams) close(err error) {
+	m.mu.Lock()
+	streams := m.streams
+	m.streams = nil
+	m.mu.Unlock()
+	for _, st := range streams {
+		st.finish(err)
+	}
+}
+
+// serveStreamFrame 处理客户端发来的流帧
+func (s *Server) serveStreamFrame(ctx context.Context, c codec.ServerCodec, streams *serverStreams, req *codec.RequestHeader) {
+	if req.Stream == codec.StreamOpen {
+		c.ReadRequestBody(nil)
+		s.openStream(ctx, c, streams, req)
+		return
+	}
+	st := streams.load(req.Sequence)
+	if st == nil {
+		c.ReadRequestBody(nil)
+		return
+	}
+	if err := st.receive(req.Stream, c.ReadRequestBody); err != nil {
+		log.Debugf("receive stream frame: %v", err)
+	}
+}
+
+func (s *Server) openStream(ctx context.Context, c codec.ServerCodec, streams *serverStreams, req *codec.RequestHeader) {
+	className, methodName, err := splitClassMethod(req.ClassMethod)
+	if err != nil {
+		s.serveStreamError(c, req, NewError(codes.InvalidHeader, err))
+		return
+	}
+	rcvr, meth, err := s.lookupClassMethod(className, methodName)
+	if err != nil {
+		s.serveStreamError(c, req, NewError(codes.InvalidHeader, err))
+		return
+	}
+	if !meth.stream {
+		s.serveStreamError(c, req, Errorf(codes.InvalidHeader, "method %s is not a stream method", req.ClassMethod))
+		return
+	}
+	if ctx, err = s.authenticate(ctx, req); err != nil {
+		s.serveStreamError(c, req, err)
+		return
+	}
+
+	ctx = WithTraceID(ctx, req.TraceID)
+	ctx = WithVerbose(ctx, req.Verbose)
+	ctx, cancel := context.WithCancel(ctx)
+	st := newStream(ctx, cancel, func(frame int, body interface{}) error {
+		return s.writeFrame(c, req, frame, body, nil)
+	})
+	streams.store(req.Sequence, st)
+
+	go func() {
+		tr := s.logger.NewTrace(true, req.Verbose, req.TraceID, req.ClientName, "", s.name, "", req.ClassMethod)
+		tr.Request(nil)
+		err := s.callStream(ctx, meth.method, rcvr, st)
+		streams.delete(req.Sequence)
+		// 被客户端取消或连接断开时不再发送结束帧
+		if st.finish(err) {
+			s.writeFrame(c, req, codec.StreamClose, nil, err)
+		}
+		tr.Response(s.rpcError(err), nil)
+	}()
+}
+
+func (s *Server) serveStreamError(c codec.ServerCodec, req *codec.RequestHeader, err error) {
+	tr := s.logger.NewTrace(true, req.Verbose, req.TraceID, req.ClientName, "", s.name, "", req.ClassMethod)
+	tr.Request(nil)
+	s.writeFrame(c, req, codec.StreamClose, nil, err)
+	tr.Response(s.rpcError(err), nil)
+}
+
+func (s *Server) callStream(ctx context.Context, method reflect.Method, rcvr reflect.Value, st Stream) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			const size = 64 << 10
+			buf := make([]byte, size)
+			buf = buf[:runtime.Stack(buf, false)]
+			log.Errorf("panic: %v\n%s", r, buf)
+
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	rets := method.Func.Call([]reflect.Value{rcvr, reflect.ValueOf(ctx), reflect.ValueOf(&st).Elem()})
+	if erri := rets[0].Interface(); erri != nil {
+		err = erri.(error)
+	}
+	return err
+}
@@ -0,0 +1,302 @@
+package rpc_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/rpc"
+	"github.com/ironzhang/zerone/rpc/codes"
+	"github.com/ironzhang/zerone/rpc/trace"
+)
+
+type Streamer struct {
+	sent     int64
+	canceled chan error
+}
+
+// Count 接收n, 发送0到n-1
+func (s *Streamer) Count(ctx context.Context, stream rpc.Stream) error {
+	var n int
+	if err := stream.Recv(&n); err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		if err := stream.Send(i); err != nil {
+			return err
+		}
+		atomic.AddInt64(&s.sent, 1)
+	}
+	return nil
+}
+
+// Sum 接收直到客户端关闭发送方向, 发送总和
+func (s *Streamer) Sum(ctx context.Context, stream rpc.Stream) error {
+	sum := 0
+	for {
+		var n int
+		err := stream.Recv(&n)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		sum += n
+	}
+	return stream.Send(sum)
+}
+
+func (s *Streamer) Echo(ctx context.Context, stream rpc.Stream) error {
+	for {
+		var m string
+		err := stream.Recv(&m)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err = stream.Send(m); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Streamer) Fail(ctx context.Context, stream rpc.Stream) error {
+	return rpc.NewError(codes.Code(1000), errors.New("fail"))
+}
+
+func (s *Streamer) Block(ctx context.Context, stream rpc.Stream) error {
+	<-ctx.Done()
+	s.canceled <- stream.Recv(nil)
+	return ctx.Err()
+}
+
+func (s *Streamer) Unary(ctx context.Context, args int, reply *int) error {
+	*reply = args
+	return nil
+}
+
+func newStreamClient(t *testing.T, s *Streamer) *rpc.Client {
+	svr := rpc.NewServer("StreamServer")
+	if err := svr.Register(s); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svr.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	cc, sc := net.Pipe()
+	go svr.ServeConn(sc)
+	c := rpc.NewClient("StreamClient", cc)
+	c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	return c
+}
+
+func TestServerStream(t *testing.T) {
+	c := newStreamClient(t, &Streamer{})
+	defer c.Close()
+
+	st, err := c.NewStream(context.Background(), "Streamer.Count")
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	if err = st.Send(200); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	for i := 0; ; i++ {
+		var n int
+		err = st.Recv(&n)
+		if err == io.EOF {
+			if i != 200 {
+				t.Errorf("recv %d messages, want 200", i)
+			}
+			break
+		}
+		if err != nil {
+			t.Fatalf("recv: %v", err)
+		}
+		if n != i {
+			t.Errorf("%d: got %v, want %v", i, n, i)
+		}
+	}
+	if err = st.Send(1); err != io.EOF {
+		t.Errorf("send after finished: got %v, want %v", err, io.EOF)
+	}
+}
+
+func TestClientStream(t *testing.T) {
+	c := newStreamClient(t, &Streamer{})
+	defer c.Close()
+
+	st, err := c.NewStream(context.Background(), "Streamer.Sum")
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	for i := 1; i <= 100; i++ {
+		if err = st.Send(i); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+	}
+	if err = st.CloseSend(); err != nil {
+		t.Fatalf("close send: %v", err)
+	}
+	if err = st.Send(1); err != rpc.ErrStreamClosed {
+		t.Errorf("send after close: got %v, want %v", err, rpc.ErrStreamClosed)
+	}
+	var sum int
+	if err = st.Recv(&sum); err != nil {
+		t.Fatalf("recv: %v", err)
+	}
+	if sum != 5050 {
+		t.Errorf("sum: got %v, want %v", sum, 5050)
+	}
+	if err = st.Recv(&sum); err != io.EOF {
+		t.Errorf("recv: got %v, want %v", err, io.EOF)
+	}
+}
+
+func TestBidiStream(t *testing.T) {
+	c := newStreamClient(t, &Streamer{})
+	defer c.Close()
+
+	st, err := c.NewStream(context.Background(), "Streamer.Echo")
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	tests := []string{"a", "b", "hello", "world"}
+	for i, tt := range tests {
+		if err = st.Send(tt); err != nil {
+			t.Fatalf("%d: send: %v", i, err)
+		}
+		var m string
+		if err = st.Recv(&m); err != nil {
+			t.Fatalf("%d: recv: %v", i, err)
+		}
+		if m != tt {
+			t.Errorf("%d: got %v, want %v", i, m, tt)
+		}
+	}
+	st.CloseSend()
+	if err = st.Recv(nil); err != io.EOF {
+		t.Errorf("recv: got %v, want %v", err, io.EOF)
+	}
+}
+
+func TestStreamError(t *testing.T) {
+	c := newStreamClient(t, &Streamer{})
+	defer c.Close()
+
+	tests := []struct {
+		method string
+		code   codes.Code
+	}{
+		{method: "Streamer.Fail", code: codes.Code(1000)},
+		{method: "Streamer.Unary", code: codes.InvalidHeader},
+		{method: "Streamer.NotFound", code: codes.InvalidHeader},
+	}
+	for i, tt := range tests {
+		st, err := c.NewStream(context.Background(), tt.method)
+		if err != nil {
+			t.Fatalf("%d: new stream: %v", i, err)
+		}
+		err = st.Recv(nil)
+		if code := errorCode(err); code != tt.code {
+			t.Errorf("%d: code: got %v, want %v", i, code, tt.code)
+		}
+	}
+
+	if err := c.Call(context.Background(), "Streamer.Echo", 1, nil, time.Second); errorCode(err) != codes.InvalidHeader {
+		t.Errorf("call stream method: got %v, want code %v", err, codes.InvalidHeader)
+	}
+}
+
+func TestStreamCancel(t *testing.T) {
+	s := &Streamer{canceled: make(chan error, 1)}
+	c := newStreamClient(t, s)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	st, err := c.NewStream(ctx, "Streamer.Block")
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	cancel()
+	if err = st.Recv(nil); err != context.Canceled {
+		t.Errorf("client recv: got %v, want %v", err, context.Canceled)
+	}
+	select {
+	case err = <-s.canceled:
+		if err != context.Canceled {
+			t.Errorf("server recv: got %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("server stream is not canceled")
+	}
+}
+
+func TestStreamFlowControl(t *testing.T) {
+	s := &Streamer{}
+	c := newStreamClient(t, s)
+	defer c.Close()
+
+	st, err := c.NewStream(context.Background(), "Streamer.Count")
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	const n = 10 * rpc.StreamWindow
+	if err = st.Send(n); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if sent := atomic.LoadInt64(&s.sent); sent != rpc.StreamWindow {
+		t.Errorf("sent before recv: got %v, want %v", sent, rpc.StreamWindow)
+	}
+
+	count := 0
+	for {
+		if err = st.Recv(nil); err != nil {
+			break
+		}
+		count++
+	}
+	if err != io.EOF {
+		t.Errorf("recv: got %v, want %v", err, io.EOF)
+	}
+	if count != n {
+		t.Errorf("count: got %v, want %v", count, n)
+	}
+}
+
+func TestClientActive(t *testing.T) {
+	s := &Streamer{}
+	c := newStreamClient(t, s)
+	defer c.Close()
+
+	st, err := c.NewStream(context.Background(), "Streamer.Echo")
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	// 打开的流计入Active, 不计入Pending
+	if got, want := c.Active(), 1; got != want {
+		t.Errorf("active: got %v, want %v", got, want)
+	}
+	if got, want := c.Pending(), 0; got != want {
+		t.Errorf("pending: got %v, want %v", got, want)
+	}
+
+	st.CloseSend()
+	if err = st.Recv(nil); err != io.EOF {
+		t.Fatalf("recv: got %v, want %v", err, io.EOF)
+	}
+	for i := 0; i < 100 && c.Active() != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := c.Active(), 0; got != want {
+		t.Errorf("active after close: got %v, want %v", got, want)
+	}
+}
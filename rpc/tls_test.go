@@ -0,0 +1,138 @@
+package rpc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/rpc"
+	"github.com/ironzhang/zerone/rpc/trace"
+)
+
+// NewTestCert 生成测试证书, parent为nil时生成自签名的CA证书
+func NewTestCert(t *testing.T, cn string, parent *tls.Certificate) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	issuer, signer := tmpl, interface{}(key)
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+	} else {
+		issuer, signer = parent.Leaf, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+type Identity int
+
+func (Identity) Whoami(ctx context.Context, args interface{}, reply *string) error {
+	if peer, ok := rpc.ParsePeer(ctx); ok {
+		*reply = peer.Identity()
+	}
+	return nil
+}
+
+func ServeTLS(t *testing.T, config *tls.Config) net.Listener {
+	ln, err := tls.Listen("tcp", "localhost:0", config)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	svr := rpc.NewServer("TLSServer")
+	if err = svr.Register(new(Identity)); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	svr.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+	go svr.Accept(ln)
+	return ln
+}
+
+func TestTLS(t *testing.T) {
+	ca := NewTestCert(t, "ca", nil)
+	server := NewTestCert(t, "server", &ca)
+	client := NewTestCert(t, "client", &ca)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.Leaf)
+
+	tests := []struct {
+		name   string
+		server *tls.Config
+		client *tls.Config
+		ok     bool
+		peer   string
+	}{
+		{
+			name:   "TLS",
+			server: &tls.Config{Certificates: []tls.Certificate{server}},
+			client: &tls.Config{RootCAs: pool},
+			ok:     true,
+			peer:   "",
+		},
+		{
+			name:   "UnknownCA",
+			server: &tls.Config{Certificates: []tls.Certificate{server}},
+			client: &tls.Config{},
+			ok:     false,
+		},
+		{
+			name:   "MutualTLS",
+			server: &tls.Config{Certificates: []tls.Certificate{server}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool},
+			client: &tls.Config{RootCAs: pool, Certificates: []tls.Certificate{client}},
+			ok:     true,
+			peer:   "client",
+		},
+		{
+			name:   "MutualTLSWithoutClientCert",
+			server: &tls.Config{Certificates: []tls.Certificate{server}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: pool},
+			client: &tls.Config{RootCAs: pool},
+			ok:     false,
+		},
+	}
+	for _, tt := range tests {
+		ln := ServeTLS(t, tt.server)
+		c, err := rpc.DialWithOptions(tt.name, "tcp", ln.Addr().String(), rpc.DialOptions{Timeout: time.Second, TLSConfig: tt.client})
+		if err == nil {
+			c.SetTraceOutput(trace.NewStdOutput(ioutil.Discard))
+			var reply string
+			if err = c.Call(context.Background(), "Identity.Whoami", nil, &reply, time.Second); err == nil {
+				if got, want := reply, tt.peer; got != want {
+					t.Errorf("%s: peer: got %q, want %q", tt.name, got, want)
+				}
+			}
+			c.Close()
+		}
+		if got, want := err == nil, tt.ok; got != want {
+			t.Errorf("%s: ok: got %v, want %v, err=%v", tt.name, got, want, err)
+		}
+		ln.Close()
+	}
+}
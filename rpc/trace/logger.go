@@ -25,6 +25,11 @@ func (p *Logger) SetVerbose(verbose int) {
 }
 
 func (p *Logger) NewTrace(server bool, verbose int, traceID, clientName, clientAddr, serverName, serverAddr, classMethod string) Trace {
+	return p.NewSubsetTrace(server, verbose, traceID, clientName, clientAddr, serverName, serverAddr, classMethod, "")
+}
+
+// NewSubsetTrace 创建记录了所选路由子集的Trace
+func (p *Logger) NewSubsetTrace(server bool, verbose int, traceID, clientName, clientAddr, serverName, serverAddr, classMethod, subset string) Trace {
 	out := p.out
 	if out == nil {
 		return nopTrace{}
@@ -43,6 +48,7 @@ func (p *Logger) NewTrace(server bool, verbose int, traceID, clientName, clientA
 			serverName:  serverName,
 			serverAddr:  serverAddr,
 			classMethod: classMethod,
+			subset:      subset,
 		}
 	} else {
 		return &verboseTrace{
@@ -54,6 +60,7 @@ func (p *Logger) NewTrace(server bool, verbose int, traceID, clientName, clientA
 			serverName:  serverName,
 			serverAddr:  serverAddr,
 			classMethod: classMethod,
+			subset:      subset,
 		}
 	}
 }
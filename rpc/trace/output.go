@@ -17,6 +17,7 @@ type Request struct {
 	ServerName  string
 	ServerAddr  string
 	ClassMethod string
+	Subset      string
 	Args        interface{}
 }
 
@@ -30,6 +31,7 @@ type Response struct {
 	ServerName  string
 	ServerAddr  string
 	ClassMethod string
+	Subset      string
 	Error       error
 	Reply       interface{}
 }
@@ -61,7 +63,7 @@ func (p *StdOutput) Request(r Request) {
 	}
 
 	args, _ := json.Marshal(r.Args)
-	fmt.Fprintf(p.w, "%s %s.Request[%s][%s:%s->%s:%s][%s]: %s\n",
+	fmt.Fprintf(p.w, "%s %s.Request[%s][%s:%s->%s:%s][%s]%s: %s\n",
 		r.Start.Format(timeLayout),
 		prefix,
 		r.TraceID,
@@ -70,6 +72,7 @@ func (p *StdOutput) Request(r Request) {
 		r.ServerName,
 		r.ServerAddr,
 		r.ClassMethod,
+		subset(r.Subset),
 		args,
 	)
 }
@@ -81,7 +84,7 @@ func (p *StdOutput) Response(r Response) {
 	}
 
 	if r.Error != nil {
-		fmt.Fprintf(p.w, "%s %s.Error[%s][%s:%s->%s:%s][%s][%s]: %s\n",
+		fmt.Fprintf(p.w, "%s %s.Error[%s][%s:%s->%s:%s][%s]%s[%s]: %s\n",
 			r.End.Format(timeLayout),
 			prefix,
 			r.TraceID,
@@ -90,12 +93,13 @@ func (p *StdOutput) Response(r Response) {
 			r.ServerName,
 			r.ServerAddr,
 			r.ClassMethod,
+			subset(r.Subset),
 			r.End.Sub(r.Start),
 			r.Error,
 		)
 	} else {
 		reply, _ := json.Marshal(r.Reply)
-		fmt.Fprintf(p.w, "%s %s.Reply[%s][%s:%s->%s:%s][%s][%s]: %s\n",
+		fmt.Fprintf(p.w, "%s %s.Reply[%s][%s:%s->%s:%s][%s]%s[%s]: %s\n",
 			r.End.Format(timeLayout),
 			prefix,
 			r.TraceID,
@@ -104,8 +108,16 @@ func (p *StdOutput) Response(r Response) {
 			r.ServerName,
 			r.ServerAddr,
 			r.ClassMethod,
+			subset(r.Subset),
 			r.End.Sub(r.Start),
 			reply,
 		)
 	}
 }
+
+func subset(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "[subset:" + s + "]"
+}
@@ -28,6 +28,7 @@ type errTrace struct {
 	serverName  string
 	serverAddr  string
 	classMethod string
+	subset      string
 	start       time.Time
 	args        interface{}
 }
@@ -49,6 +50,7 @@ func (p *errTrace) Response(err error, reply interface{}) {
 			ServerName:  p.serverName,
 			ServerAddr:  p.serverAddr,
 			ClassMethod: p.classMethod,
+			Subset:      p.subset,
 			Args:        p.args,
 		})
 		p.out.Response(Response{
@@ -61,6 +63,7 @@ func (p *errTrace) Response(err error, reply interface{}) {
 			ServerName:  p.serverName,
 			ServerAddr:  p.serverAddr,
 			ClassMethod: p.classMethod,
+			Subset:      p.subset,
 			Error:       err,
 		})
 	}
@@ -75,6 +78,7 @@ type verboseTrace struct {
 	serverName  string
 	serverAddr  string
 	classMethod string
+	subset      string
 	start       time.Time
 }
 
@@ -89,6 +93,7 @@ func (p *verboseTrace) Request(args interface{}) {
 		ServerName:  p.serverName,
 		ServerAddr:  p.serverAddr,
 		ClassMethod: p.classMethod,
+		Subset:      p.subset,
 		Args:        args,
 	})
 }
@@ -104,6 +109,7 @@ func (p *verboseTrace) Response(err error, reply interface{}) {
 		ServerName:  p.serverName,
 		ServerAddr:  p.serverAddr,
 		ClassMethod: p.classMethod,
+		Subset:      p.subset,
 		Error:       err,
 		Reply:       reply,
 	})
@@ -0,0 +1,68 @@
+package zclient
+
+import (
+	"math/rand"
+	"time"
+)
+
+// BackoffOptions 重连退避选项, 第n次重连前等待BaseDelay*Multiplier^(n-1), 最长为MaxDelay
+type BackoffOptions struct {
+	BaseDelay  time.Duration // 首次重连前的等待时间, 默认为100ms
+	Multiplier float64       // 每次重连失败后等待时间的增长倍数, 默认为1.6
+	Jitter     float64       // 等待时间的随机抖动比例, 默认为0.2
+	MaxDelay   time.Duration // 最长等待时间, 默认为30s
+}
+
+func (o *BackoffOptions) setDefaults() {
+	if o.BaseDelay <= 0 {
+		o.BaseDelay = 100 * time.Millisecond
+	}
+	if o.Multiplier < 1 {
+		o.Multiplier = 1.6
+	}
+	if o.Jitter <= 0 {
+		o.Jitter = 0.2
+	}
+	if o.MaxDelay <= 0 {
+		o.MaxDelay = 30 * time.Second
+	}
+}
+
+// delay 返回第retries次重连前的等待时间
+func (o *BackoffOptions) delay(retries int) time.Duration {
+	d := float64(o.BaseDelay)
+	for i := 1; i < retries && d < float64(o.MaxDelay); i++ {
+		d *= o.Multiplier
+	}
+	if d > float64(o.MaxDelay) {
+		d = float64(o.MaxDelay)
+	}
+	d *= 1 + o.Jitter*(2*rand.Float64()-1)
+	return time.Duration(d)
+}
+
+// ConnState endpoint的连接状态
+type ConnState int
+
+// 连接状态常量定义
+const (
+	Idle             ConnState = iota // 尚未建立连接
+	Connecting                        // 正在建立连接
+	Ready                             // 连接已建立
+	TransientFailure                  // 建立连接失败, 等待退避时间后重连, 期间的调用直接失败
+)
+
+func (s ConnState) String() string {
+	switch s {
+	case Idle:
+		return "IDLE"
+	case Connecting:
+		return "CONNECTING"
+	case Ready:
+		return "READY"
+	case TransientFailure:
+		return "TRANSIENT_FAILURE"
+	default:
+		return "UNKNOWN"
+	}
+}
@@ -0,0 +1,75 @@
+package zclient
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/rpc"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	o := BackoffOptions{BaseDelay: time.Second, Multiplier: 2, Jitter: 0.1, MaxDelay: 10 * time.Second}
+	o.setDefaults()
+
+	tests := []struct {
+		retries int
+		delay   time.Duration
+	}{
+		{retries: 1, delay: time.Second},
+		{retries: 2, delay: 2 * time.Second},
+		{retries: 3, delay: 4 * time.Second},
+		{retries: 4, delay: 8 * time.Second},
+		{retries: 5, delay: 10 * time.Second},
+		{retries: 100, delay: 10 * time.Second},
+	}
+	for i, tt := range tests {
+		d := o.delay(tt.retries)
+		min := time.Duration(float64(tt.delay) * 0.9)
+		max := time.Duration(float64(tt.delay) * 1.1)
+		if d < min || d > max {
+			t.Errorf("%d: delay %v not in [%v, %v]", i, d, min, max)
+		}
+	}
+}
+
+func TestConnectorBackoff(t *testing.T) {
+	c := newConnector("", ConnectionOptions{Backoff: BackoffOptions{BaseDelay: 50 * time.Millisecond, Jitter: 0.01}})
+	defer c.close()
+
+	key := dialKey("tcp", "localhost:4999")
+	if got, want := c.connState(key), Idle; got != want {
+		t.Fatalf("state: got %v, want %v", got, want)
+	}
+
+	// 首次建连失败返回实际的错误
+	if _, err := c.dial(key, "tcp", "localhost:4999", false); err == nil || err == rpc.ErrUnavailable {
+		t.Fatalf("dial: unexpected error: %v", err)
+	}
+	if got, want := c.connState(key), TransientFailure; got != want {
+		t.Fatalf("state: got %v, want %v", got, want)
+	}
+
+	// 退避期间直接失败
+	if _, err := c.dial(key, "tcp", "localhost:4999", false); err != rpc.ErrUnavailable {
+		t.Fatalf("dial: got %v, want %v", err, rpc.ErrUnavailable)
+	}
+
+	// 退避结束后重连
+	time.Sleep(60 * time.Millisecond)
+	if _, err := c.dial(key, "tcp", "localhost:4999", false); err == nil || err == rpc.ErrUnavailable {
+		t.Fatalf("dial: unexpected error: %v", err)
+	}
+}
+
+func TestConnectorReady(t *testing.T) {
+	c := newConnector("", ConnectionOptions{})
+	defer c.close()
+
+	key := dialKey("tcp", "localhost:4000")
+	if _, err := c.dial(key, "tcp", "localhost:4000", false); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if got, want := c.connState(key), Ready; got != want {
+		t.Fatalf("state: got %v, want %v", got, want)
+	}
+}
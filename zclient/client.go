@@ -2,7 +2,6 @@ package zclient
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"sync"
 	"sync/atomic"
@@ -11,6 +10,8 @@ import (
 	"github.com/ironzhang/zerone/pkg/balance"
 	"github.com/ironzhang/zerone/pkg/endpoint"
 	"github.com/ironzhang/zerone/pkg/route"
+	"github.com/ironzhang/zerone/pkg/route/outlier"
+	"github.com/ironzhang/zerone/pkg/route/split"
 	"github.com/ironzhang/zerone/rpc"
 	"github.com/ironzhang/zerone/rpc/trace"
 )
@@ -24,41 +25,95 @@ const (
 	RoundRobinBalancer BalancePolicy = balance.RoundRobinBalancerName
 	HashBalancer       BalancePolicy = balance.HashBalancerName
 	NodeBalancer       BalancePolicy = balance.NodeBalancerName
+
+	ConsistentHashBalancer BalancePolicy = balance.ConsistentHashBalancerName
 )
 
+// Options 客户端选项
+type Options struct {
+	HealthCheck HealthCheckOptions // 主动健康检查选项
+	Outlier     *outlier.Options   // 异常endpoint检测选项, 为nil时不检测
+	Connection  ConnectionOptions  // 连接管理选项
+}
+
 type Client struct {
 	shutdown      *int32
 	table         route.Table
+	available     route.Table // 参与负载均衡的路由表
+	health        *healthChecker
+	outlier       *outlier.Table
 	connector     *connector
 	balance       *balance.Manager
 	balancePolicy BalancePolicy
 	failPolicy    FailPolicy
+	splitter      *splitter
+	splitters     *splitters // Client及其副本创建的splitter
+	unwatch       func()
 }
 
 func New(name string, table route.Table) *Client {
-	return &Client{
+	return NewWithOptions(name, table, Options{})
+}
+
+func NewWithOptions(name string, table route.Table, opts Options) *Client {
+	c := &Client{
 		shutdown:      new(int32),
 		table:         table,
-		connector:     newConnector(name),
-		balance:       balance.NewManager(table, nil),
+		available:     table,
+		connector:     newConnector(name, opts.Connection),
 		balancePolicy: RandomBalancer,
 		failPolicy:    NewFailtry(0, 0, 0),
+		splitters:     new(splitters),
+	}
+	if opts.HealthCheck.Interval > 0 {
+		c.health = newHealthChecker(c.available, c.connector, opts.HealthCheck)
+		c.available = c.health
+	}
+	if opts.Outlier != nil {
+		o := *opts.Outlier
+		if o.IsFailure == nil {
+			o.IsFailure = isOutlierFailure
+		}
+		c.outlier = outlier.NewTable(c.available, o)
+		c.available = c.outlier
 	}
+	if w, ok := table.(route.Watchable); ok {
+		c.unwatch = w.Watch(func(route.Event) {
+			c.connector.retain(table.ListEndpoints())
+		})
+	}
+	go c.connector.cleaning(table)
+	c.balance = balance.NewManager(c.available, nil)
+	return c
 }
 
 func (c *Client) clone() *Client {
 	return &Client{
 		shutdown:      c.shutdown,
 		table:         c.table,
+		available:     c.available,
+		health:        c.health,
+		outlier:       c.outlier,
 		connector:     c.connector,
 		balance:       c.balance,
 		balancePolicy: c.balancePolicy,
 		failPolicy:    c.failPolicy,
+		splitter:      c.splitter,
+		splitters:     c.splitters,
+		unwatch:       c.unwatch,
 	}
 }
 
 func (c *Client) Close() error {
 	if atomic.CompareAndSwapInt32(c.shutdown, 0, 1) {
+		if c.unwatch != nil {
+			c.unwatch()
+		}
+		if c.health != nil {
+			c.health.close()
+		}
+		c.balance.Close()
+		c.splitters.close()
 		c.connector.close()
 		if closer, ok := c.table.(io.Closer); ok {
 			closer.Close()
@@ -84,6 +139,24 @@ func (c *Client) ListEndpoints() []endpoint.Endpoint {
 	return c.table.ListEndpoints()
 }
 
+// ListEndpointStatuses 返回路由表中所有endpoint的健康状态和连接状态, 未开启健康检查时均为健康
+func (c *Client) ListEndpointStatuses() []EndpointStatus {
+	var res []EndpointStatus
+	if c.health != nil {
+		res = c.health.listEndpointStatuses()
+	} else {
+		eps := c.table.ListEndpoints()
+		res = make([]EndpointStatus, 0, len(eps))
+		for _, ep := range eps {
+			res = append(res, EndpointStatus{Endpoint: ep, Healthy: true})
+		}
+	}
+	for i := range res {
+		res[i].State = c.connector.connState(endpointKey(res[i].Endpoint))
+	}
+	return res
+}
+
 func (c *Client) WithBalancePolicy(policy BalancePolicy) *Client {
 	nc := c.clone()
 	nc.balancePolicy = policy
@@ -96,19 +169,125 @@ func (c *Client) WithFailPolicy(policy FailPolicy) *Client {
 	return nc
 }
 
+// WithRouter 按router的切分规则将流量路由到不同的endpoint子集
+func (c *Client) WithRouter(router *split.Router) *Client {
+	nc := c.clone()
+	nc.splitter = newSplitter(c.available, router)
+	c.splitters.add(nc.splitter)
+	return nc
+}
+
 func (c *Client) Go(ctx context.Context, key []byte, method string, args, res interface{}, timeout time.Duration, done chan *rpc.Call) (*rpc.Call, error) {
 	if atomic.LoadInt32(c.shutdown) == 1 {
 		return nil, rpc.ErrShutdown
 	}
 
-	lb := c.balance.GetLoadBalancer(string(c.balancePolicy))
-	return c.failPolicy.execute(lb, key, func(net, addr string) (*rpc.Call, error) {
-		rc, err := c.connector.dial(fmt.Sprintf("%s://%s", net, addr), net, addr)
+	return c.execute(ctx, key, func(ctx context.Context, rc *rpc.Client) (*rpc.Call, error) {
+		return rc.Go(ctx, method, args, res, timeout, done)
+	})
+}
+
+// GoBatch 将多个调用打包发送到同一个endpoint, 失败策略只在发送失败时生效
+func (c *Client) GoBatch(ctx context.Context, key []byte, calls []*rpc.BatchCall, parallel bool, timeout time.Duration, done chan *rpc.Call) (*rpc.Call, error) {
+	if atomic.LoadInt32(c.shutdown) == 1 {
+		return nil, rpc.ErrShutdown
+	}
+	return c.execute(ctx, key, func(ctx context.Context, rc *rpc.Client) (*rpc.Call, error) {
+		return rc.GoBatch(ctx, calls, parallel, timeout, done)
+	})
+}
+
+// Batch 同步批量调用, 各个调用的结果见BatchCall.Error
+func (c *Client) Batch(ctx context.Context, key []byte, calls []*rpc.BatchCall, parallel bool, timeout time.Duration) error {
+	call, err := c.GoBatch(ctx, key, calls, parallel, timeout, make(chan *rpc.Call, 1))
+	if err != nil {
+		return err
+	}
+	<-call.Done
+	return call.Error
+}
+
+// execute 按失败策略选择endpoint执行调用, 并将调用结果报告给异常endpoint检测
+func (c *Client) execute(ctx context.Context, key []byte, do func(ctx context.Context, rc *rpc.Client) (*rpc.Call, error)) (*rpc.Call, error) {
+	ctx, lb := c.loadBalancer(ctx, key)
+	return c.failPolicy.execute(lb, key, func(ep endpoint.Endpoint) (*rpc.Call, error) {
+		rc, err := c.connector.dial(endpointKey(ep), ep.Net, ep.Addr, ep.TLS)
 		if err != nil {
+			c.report(ep.Net, ep.Addr, err)
 			return nil, err
 		}
-		return rc.Go(ctx, method, args, res, timeout, done)
+		cctx := ctx
+		if c.outlier != nil {
+			cctx = rpc.WithCallDone(ctx, func(call *rpc.Call) {
+				c.report(ep.Net, ep.Addr, call.Error)
+			})
+		}
+		call, err := do(cctx, rc)
+		if err != nil {
+			c.report(ep.Net, ep.Addr, err)
+		}
+		return call, err
+	})
+}
+
+// Notify 单向调用, 失败策略只在发送失败时生效, 服务端的处理结果不可知
+func (c *Client) Notify(ctx context.Context, key []byte, method string, args interface{}) error {
+	if atomic.LoadInt32(c.shutdown) == 1 {
+		return rpc.ErrShutdown
+	}
+
+	ctx, lb := c.loadBalancer(ctx, key)
+	_, err := c.failPolicy.execute(lb, key, func(ep endpoint.Endpoint) (*rpc.Call, error) {
+		rc, err := c.connector.dial(endpointKey(ep), ep.Net, ep.Addr, ep.TLS)
+		if err != nil {
+			c.report(ep.Net, ep.Addr, err)
+			return nil, err
+		}
+		err = rc.Notify(ctx, method, args)
+		c.report(ep.Net, ep.Addr, err)
+		return nil, err
 	})
+	return err
+}
+
+// NewStream 按key选择endpoint打开流, 流无法重放, 因此不使用失败策略重试
+func (c *Client) NewStream(ctx context.Context, key []byte, method string) (rpc.ClientStream, error) {
+	if atomic.LoadInt32(c.shutdown) == 1 {
+		return nil, rpc.ErrShutdown
+	}
+
+	ctx, lb := c.loadBalancer(ctx, key)
+	ep, err := lb.GetEndpoint(key)
+	if err != nil {
+		return nil, err
+	}
+	rc, err := c.connector.dial(endpointKey(ep), ep.Net, ep.Addr, ep.TLS)
+	if err != nil {
+		c.report(ep.Net, ep.Addr, err)
+		return nil, err
+	}
+	st, err := rc.NewStream(ctx, method)
+	if err != nil {
+		c.report(ep.Net, ep.Addr, err)
+	}
+	return st, err
+}
+
+// loadBalancer 返回key对应的负载均衡器, 命中分流规则时将子集记录到ctx中
+func (c *Client) loadBalancer(ctx context.Context, key []byte) (context.Context, balance.LoadBalancer) {
+	lb := c.balance.GetLoadBalancer(string(c.balancePolicy))
+	if c.splitter != nil {
+		if subset, slb, ok := c.splitter.getLoadBalancer(key, string(c.balancePolicy)); ok {
+			return rpc.WithSubset(ctx, subset), slb
+		}
+	}
+	return ctx, lb
+}
+
+func (c *Client) report(net, addr string, err error) {
+	if c.outlier != nil {
+		c.outlier.Report(net, addr, err)
+	}
 }
 
 func (c *Client) Call(ctx context.Context, key []byte, method string, args, res interface{}, timeout time.Duration) error {
@@ -130,10 +309,10 @@ type Result struct {
 
 func (c *Client) Broadcast(ctx context.Context, method string, args, res interface{}, timeout time.Duration) <-chan Result {
 	var wg sync.WaitGroup
-	eps := c.table.ListEndpoints()
+	eps := c.available.ListEndpoints()
 	ch := make(chan Result, len(eps))
 	for _, ep := range eps {
-		rc, err := c.connector.dial(fmt.Sprintf("%s://%s", ep.Net, ep.Addr), ep.Net, ep.Addr)
+		rc, err := c.connector.dial(endpointKey(ep), ep.Net, ep.Addr, ep.TLS)
 		if err != nil {
 			ch <- Result{
 				Endpoint: ep,
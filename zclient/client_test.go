@@ -2,15 +2,22 @@ package zclient
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"net"
+	"reflect"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route/split"
 	"github.com/ironzhang/zerone/pkg/route/stable"
 	"github.com/ironzhang/zerone/rpc"
+	"github.com/ironzhang/zerone/rpc/trace"
 )
 
-type Echo int
+type Echo int64
 
 func (p *Echo) Echo(ctx context.Context, args string, reply *string) error {
 	*reply = args
@@ -18,12 +25,31 @@ func (p *Echo) Echo(ctx context.Context, args string, reply *string) error {
 }
 
 func (p *Echo) Inc(ctx context.Context, args interface{}, reply *int) error {
-	*reply = int(*p)
-	(*p)++
+	*reply = int(atomic.AddInt64((*int64)(p), 1) - 1)
 	return nil
 }
 
-func ServeEcho(network, address string) {
+func (p *Echo) Sleep(ctx context.Context, args time.Duration, reply *int) error {
+	time.Sleep(args)
+	return nil
+}
+
+func (p *Echo) Stream(ctx context.Context, stream rpc.Stream) error {
+	for {
+		var m string
+		if err := stream.Recv(&m); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if err := stream.Send(m); err != nil {
+			return err
+		}
+	}
+}
+
+func ServeEcho(network, address string) net.Listener {
 	ln, err := net.Listen(network, address)
 	if err != nil {
 		panic(err)
@@ -35,6 +61,7 @@ func ServeEcho(network, address string) {
 	}
 
 	go svr.Accept(ln)
+	return ln
 }
 
 func init() {
@@ -43,7 +70,7 @@ func init() {
 
 func TestClientCall(t *testing.T) {
 	tb := stable.NewTable([]endpoint.Endpoint{
-		{"0", "tcp", "localhost:4000", 0},
+		{Name: "0", Net: "tcp", Addr: "localhost:4000", Load: 0},
 	})
 	c := New("Client", tb)
 	defer c.Close()
@@ -60,11 +87,86 @@ func TestClientCall(t *testing.T) {
 	}
 }
 
+func TestClientNotify(t *testing.T) {
+	tb := stable.NewTable([]endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:4000", Load: 0},
+	})
+	c := New("Client", tb)
+	defer c.Close()
+
+	if err := c.Notify(context.Background(), nil, "Echo.Echo", "hello"); err != nil {
+		t.Fatalf("notify: %v", err)
+	}
+
+	c.Close()
+	if err := c.Notify(context.Background(), nil, "Echo.Echo", "hello"); err != rpc.ErrShutdown {
+		t.Errorf("notify after close: got %v, want %v", err, rpc.ErrShutdown)
+	}
+}
+
+func TestClientBatch(t *testing.T) {
+	tb := stable.NewTable([]endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:4000", Load: 0},
+	})
+	c := New("Client", tb)
+	defer c.Close()
+
+	replies := make([]string, 3)
+	calls := make([]*rpc.BatchCall, 0, len(replies)+1)
+	for i := range replies {
+		calls = append(calls, &rpc.BatchCall{ClassMethod: "Echo.Echo", Args: fmt.Sprint(i), Reply: &replies[i]})
+	}
+	calls = append(calls, &rpc.BatchCall{ClassMethod: "Echo.NotFound"})
+	if err := c.Batch(context.Background(), nil, calls, true, time.Second); err != nil {
+		t.Fatalf("batch: %v", err)
+	}
+	for i, reply := range replies {
+		if calls[i].Error != nil {
+			t.Errorf("%d: error: %v", i, calls[i].Error)
+		}
+		if want := fmt.Sprint(i); reply != want {
+			t.Errorf("%d: got %v, want %v", i, reply, want)
+		}
+	}
+	if calls[3].Error == nil {
+		t.Errorf("call not found method: error is nil")
+	}
+}
+
+func TestClientNewStream(t *testing.T) {
+	tb := stable.NewTable([]endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:4000", Load: 0},
+	})
+	c := New("Client", tb)
+	defer c.Close()
+
+	st, err := c.NewStream(context.Background(), nil, "Echo.Stream")
+	if err != nil {
+		t.Fatalf("new stream: %v", err)
+	}
+	for i := 0; i < 10; i++ {
+		args, reply := fmt.Sprint(i), ""
+		if err = st.Send(args); err != nil {
+			t.Fatalf("send: %v", err)
+		}
+		if err = st.Recv(&reply); err != nil {
+			t.Fatalf("recv: %v", err)
+		}
+		if args != reply {
+			t.Errorf("%d: got %v, want %v", i, reply, args)
+		}
+	}
+	st.CloseSend()
+	if err = st.Recv(nil); err != io.EOF {
+		t.Errorf("recv: got %v, want %v", err, io.EOF)
+	}
+}
+
 func TestClientBroadcast(t *testing.T) {
 	tb := stable.NewTable([]endpoint.Endpoint{
-		{"0", "tcp", "localhost:4000", 0},
-		{"1", "tcp", "localhost:4000", 0},
-		{"2", "tcp", "localhost:4000", 0},
+		{Name: "0", Net: "tcp", Addr: "localhost:4000", Load: 0},
+		{Name: "1", Net: "tcp", Addr: "localhost:4000", Load: 0},
+		{Name: "2", Net: "tcp", Addr: "localhost:4000", Load: 0},
 	})
 	c := New("Client", tb)
 	defer c.Close()
@@ -82,7 +184,7 @@ func TestClientBroadcast(t *testing.T) {
 
 func TestClientWithBalancePolicy(t *testing.T) {
 	tb := stable.NewTable([]endpoint.Endpoint{
-		{"0", "tcp", "localhost:4000", 0},
+		{Name: "0", Net: "tcp", Addr: "localhost:4000", Load: 0},
 	})
 	c := New("Client", tb)
 	defer c.Close()
@@ -98,3 +200,68 @@ func TestClientWithBalancePolicy(t *testing.T) {
 		t.Logf("args(%s) == reply(%s)", args, reply)
 	}
 }
+
+type SubsetOutput struct {
+	subsets []string
+}
+
+func (p *SubsetOutput) Request(r trace.Request) {
+	p.subsets = append(p.subsets, r.Subset)
+}
+
+func (p *SubsetOutput) Response(r trace.Response) {
+}
+
+func TestClientWithRouter(t *testing.T) {
+	tb := stable.NewTable([]endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:4000", Tags: endpoint.NewTags(map[string]string{"version": "v1"})},
+		{Name: "1", Net: "tcp", Addr: "localhost:4000", Tags: endpoint.NewTags(map[string]string{"version": "v2"})},
+	})
+	r := split.NewRouter()
+	if err := r.SetRules([]split.Rule{{Name: "canary", Tags: map[string]string{"version": "v2"}, Percent: 100}}); err != nil {
+		t.Fatalf("set rules: %v", err)
+	}
+
+	out := &SubsetOutput{}
+	c := New("Client", tb)
+	defer c.Close()
+	c.SetTraceOutput(out)
+	c.SetTraceVerbose(1)
+
+	var reply string
+	if err := c.WithRouter(r).Call(context.Background(), []byte("user"), "Echo.Echo", "hello", &reply, 0); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if err := c.Call(context.Background(), []byte("user"), "Echo.Echo", "hello", &reply, 0); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got, want := out.subsets, []string{"canary", ""}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("subsets: got %v, want %v", got, want)
+	} else {
+		t.Logf("subsets: got %v", got)
+	}
+}
+
+func BenchmarkClientParallelCall(b *testing.B) {
+	sizes := []int{1, 4}
+	for _, size := range sizes {
+		b.Run(fmt.Sprintf("PoolSize%d", size), func(b *testing.B) {
+			tb := stable.NewTable([]endpoint.Endpoint{
+				{Name: "0", Net: "tcp", Addr: "localhost:4000", Load: 0},
+			})
+			c := NewWithOptions("Client", tb, Options{Connection: ConnectionOptions{PoolSize: size, PoolPolicy: LeastPendingPool}})
+			defer c.Close()
+
+			b.SetParallelism(50)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				args, reply := "hello, world", ""
+				for pb.Next() {
+					if err := c.Call(context.Background(), nil, "Echo.Echo", args, &reply, 0); err != nil {
+						b.Fatalf("call: %v", err)
+					}
+				}
+			})
+		})
+	}
+}
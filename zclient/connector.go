@@ -1,37 +1,333 @@
 package zclient
 
 import (
+	"crypto/tls"
+	"math/rand"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
 	"github.com/ironzhang/zerone/rpc"
 	"github.com/ironzhang/zerone/rpc/trace"
 )
 
+// PoolPolicy 连接池中选择连接的策略
+type PoolPolicy string
+
+// 连接选择策略常量定义
+const (
+	RoundRobinPool   PoolPolicy = "RoundRobin"   // 轮询
+	LeastPendingPool PoolPolicy = "LeastPending" // 选择未完成调用最少的连接
+)
+
+// ConnectionOptions 连接管理选项
+type ConnectionOptions struct {
+	IdleTimeout            time.Duration   // 连接空闲多久后关闭, 为0时不关闭空闲连接
+	MaxConnectionAge       time.Duration   // 连接的最长存活时间, 到期后不再使用并在调用全部完成后关闭, 为0时不限制
+	MaxConnectionAgeJitter float64         // 最长存活时间的随机抖动比例, 避免连接同时重建, 默认为0.1
+	CleanInterval          time.Duration   // 清理连接的间隔, 默认为10s
+	PoolSize               int             // 每个endpoint的最大连接数, 默认为1
+	PoolPolicy             PoolPolicy      // 连接选择策略, 默认为RoundRobinPool
+	PoolScaleThreshold     int             // 选中连接的未完成调用数达到该值且连接数小于PoolSize时新建连接, 默认为8
+	Dial                   rpc.DialOptions // 建立连接的选项, Dial.Timeout默认为3s, Dial.TLSConfig只用于声明了TLS的endpoint
+	Backoff                BackoffOptions  // 重连退避选项
+}
+
+func (o *ConnectionOptions) setDefaults() {
+	if o.MaxConnectionAgeJitter <= 0 {
+		o.MaxConnectionAgeJitter = 0.1
+	}
+	if o.CleanInterval <= 0 {
+		o.CleanInterval = 10 * time.Second
+	}
+	if o.PoolSize <= 0 {
+		o.PoolSize = 1
+	}
+	if o.PoolPolicy == "" {
+		o.PoolPolicy = RoundRobinPool
+	}
+	if o.PoolScaleThreshold <= 0 {
+		o.PoolScaleThreshold = 8
+	}
+	if o.Dial.Timeout <= 0 {
+		o.Dial.Timeout = 3 * time.Second
+	}
+	o.Backoff.setDefaults()
+}
+
+type conn struct {
+	client *rpc.Client
+	expire time.Time
+}
+
+// pool 同一个endpoint的连接池
+type pool struct {
+	mu       sync.Mutex
+	conns    []*conn
+	next     int
+	lastUsed int64
+
+	state   ConnState
+	retries int
+	retryAt time.Time
+	wait    chan struct{}
+}
+
+func (p *pool) use(now time.Time) {
+	atomic.StoreInt64(&p.lastUsed, now.UnixNano())
+}
+
+func (p *pool) idle(now time.Time, timeout time.Duration) bool {
+	if now.Sub(time.Unix(0, atomic.LoadInt64(&p.lastUsed))) < timeout {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.conns {
+		if c.client.Active() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// pick 按策略选择一个连接, 并移除不可用的连接, scale表示是否需要新建连接
+func (p *pool) pick(opts *ConnectionOptions) (c *rpc.Client, scale bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := p.conns[:0]
+	for _, c := range p.conns {
+		if c.client.IsAvailable() {
+			conns = append(conns, c)
+		} else {
+			c.client.Close()
+		}
+	}
+	p.conns = conns
+	if len(conns) <= 0 {
+		return nil, true
+	}
+
+	switch opts.PoolPolicy {
+	case LeastPendingPool:
+		c = conns[0].client
+		for _, cc := range conns[1:] {
+			if cc.client.Pending() < c.Pending() {
+				c = cc.client
+			}
+		}
+	default:
+		c = conns[p.next%len(conns)].client
+		p.next++
+	}
+	return c, len(conns) < opts.PoolSize && c.Pending() >= opts.PoolScaleThreshold
+}
+
+// connect 开始建立新连接, 同一时刻只允许一个建连过程, 退避期间不允许建连.
+// 返回false时, 若有正在进行的建连过程则返回等待其结束的通道
+func (p *pool) connect(now time.Time) (ok bool, wait <-chan struct{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case p.state == Connecting:
+		return false, p.wait
+	case p.state == TransientFailure && now.Before(p.retryAt):
+		return false, nil
+	}
+	p.state = Connecting
+	p.wait = make(chan struct{})
+	return true, nil
+}
+
+// ready 建连成功, 将新连接加入连接池
+func (p *pool) ready(c *conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conns = append(p.conns, c)
+	p.state = Ready
+	p.retries = 0
+	close(p.wait)
+}
+
+// add 将其他连接池建立的连接加入连接池, 不改变连接池的建连状态
+func (p *pool) add(c *conn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.conns = append(p.conns, c)
+}
+
+// fail 建连失败, 进入退避状态
+func (p *pool) fail(now time.Time, backoff *BackoffOptions) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.retries++
+	p.retryAt = now.Add(backoff.delay(p.retries))
+	p.state = TransientFailure
+	close(p.wait)
+}
+
+// connState 返回连接池的连接状态, 有可用连接时为Ready
+func (p *pool) connState() ConnState {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, c := range p.conns {
+		if c.client.IsAvailable() {
+			return Ready
+		}
+	}
+	if p.state == Ready {
+		return Idle
+	}
+	return p.state
+}
+
+// removeExpired 移除并返回已过期的连接
+func (p *pool) removeExpired(now time.Time) []*rpc.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var expired []*rpc.Client
+	conns := p.conns[:0]
+	for _, c := range p.conns {
+		if !c.expire.IsZero() && now.After(c.expire) {
+			expired = append(expired, c.client)
+		} else {
+			conns = append(conns, c)
+		}
+	}
+	p.conns = conns
+	return expired
+}
+
+func (p *pool) clients() []*rpc.Client {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	res := make([]*rpc.Client, 0, len(p.conns))
+	for _, c := range p.conns {
+		res = append(res, c.client)
+	}
+	return res
+}
+
 type connector struct {
-	name    string
+	name      string
+	opts      ConnectionOptions
+	done      chan struct{}
+	closeOnce sync.Once
+
 	mu      sync.RWMutex
 	output  trace.Output
 	verbose int
-	clients map[string]*rpc.Client
+	pools   map[string]*pool
+	retired []*rpc.Client
 }
 
-func newConnector(name string) *connector {
+func newConnector(name string, opts ConnectionOptions) *connector {
+	opts.setDefaults()
 	return &connector{
 		name:    name,
+		opts:    opts,
+		done:    make(chan struct{}),
 		output:  trace.DefaultOutput,
 		verbose: 0,
-		clients: make(map[string]*rpc.Client),
+		pools:   make(map[string]*pool),
 	}
 }
 
 func (p *connector) close() {
+	p.closeOnce.Do(func() {
+		close(p.done)
+	})
+
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	for _, c := range p.clients {
+	for _, pl := range p.pools {
+		for _, c := range pl.clients() {
+			c.Close()
+		}
+	}
+	for _, c := range p.retired {
 		c.Close()
 	}
-	p.clients = make(map[string]*rpc.Client)
+	p.pools = make(map[string]*pool)
+	p.retired = nil
+}
+
+// cleaning 定期清理路由表中已不存在的endpoint的连接, 以及空闲和过期的连接, connector关闭后退出
+func (p *connector) cleaning(table route.Table) {
+	t := time.NewTicker(p.opts.CleanInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			p.clean(time.Now(), table.ListEndpoints())
+		case <-p.done:
+			return
+		}
+	}
+}
+
+func (p *connector) clean(now time.Time, eps []endpoint.Endpoint) {
+	keys := make(map[string]bool, len(eps))
+	for _, ep := range eps {
+		keys[endpointKey(ep)] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, pl := range p.pools {
+		if !keys[key] || (p.opts.IdleTimeout > 0 && pl.idle(now, p.opts.IdleTimeout)) {
+			delete(p.pools, key)
+			p.retire(pl.clients()...)
+			continue
+		}
+		p.retire(pl.removeExpired(now)...)
+	}
+
+	retired := p.retired[:0]
+	for _, c := range p.retired {
+		if c.Active() > 0 && c.IsAvailable() {
+			retired = append(retired, c)
+		} else {
+			c.Close()
+		}
+	}
+	p.retired = retired
+}
+
+// retain 关闭并删除不在eps中的endpoint的连接
+func (p *connector) retain(eps []endpoint.Endpoint) {
+	keys := make(map[string]bool, len(eps))
+	for _, ep := range eps {
+		keys[endpointKey(ep)] = true
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, pl := range p.pools {
+		if !keys[key] {
+			delete(p.pools, key)
+			p.retire(pl.clients()...)
+		}
+	}
+}
+
+// retire 关闭不再使用的连接, 还有未完成的调用或打开的流时延迟到其结束后再关闭
+func (p *connector) retire(clients ...*rpc.Client) {
+	for _, c := range clients {
+		if c.Active() > 0 && c.IsAvailable() {
+			p.retired = append(p.retired, c)
+		} else {
+			c.Close()
+		}
+	}
 }
 
 func (p *connector) setTraceOutput(output trace.Output) {
@@ -39,8 +335,10 @@ func (p *connector) setTraceOutput(output trace.Output) {
 	defer p.mu.Unlock()
 
 	p.output = output
-	for _, c := range p.clients {
-		c.SetTraceOutput(output)
+	for _, pl := range p.pools {
+		for _, c := range pl.clients() {
+			c.SetTraceOutput(output)
+		}
 	}
 }
 
@@ -55,57 +353,128 @@ func (p *connector) setTraceVerbose(verbose int) {
 	defer p.mu.Unlock()
 
 	p.verbose = verbose
-	for _, c := range p.clients {
-		c.SetTraceVerbose(verbose)
+	for _, pl := range p.pools {
+		for _, c := range pl.clients() {
+			c.SetTraceVerbose(verbose)
+		}
 	}
 }
 
-func (p *connector) dial(key, net, addr string) (*rpc.Client, error) {
-	if c, ok := p.loadClient(key); ok {
-		if c.IsShutdown() {
-			return nil, rpc.ErrShutdown
-		} else if c.IsAvailable() {
+// dial 从endpoint的连接池中选择一个连接, 连接池为空或选中的连接负载过高时新建连接.
+// 建连失败后按退避策略等待一段时间才会重连, 期间没有可用连接的调用直接返回rpc.ErrUnavailable
+func (p *connector) dial(key, net, addr string, secure bool) (*rpc.Client, error) {
+	pl := p.loadPool(key)
+	c, scale := pl.pick(&p.opts)
+	if !scale {
+		return c, nil
+	}
+
+	ok, wait := pl.connect(time.Now())
+	if !ok {
+		if c != nil {
 			return c, nil
-		} else {
-			p.deleteClient(key)
-			c.Close()
 		}
+		if wait != nil {
+			<-wait
+			if c, _ = pl.pick(&p.opts); c != nil {
+				return c, nil
+			}
+		}
+		return nil, rpc.ErrUnavailable
 	}
 
-	c, err := rpc.Dial(p.name, net, addr)
+	nc, err := p.newClient(net, addr, secure)
 	if err != nil {
+		pl.fail(time.Now(), &p.opts.Backoff)
+		if c != nil {
+			return c, nil
+		}
 		return nil, err
 	}
-	c.SetTraceOutput(p.output)
-	c.SetTraceVerbose(p.verbose)
+	cn := &conn{client: nc}
+	if p.opts.MaxConnectionAge > 0 {
+		cn.expire = time.Now().Add(p.maxAge())
+	}
 
-	actual, loaded := p.loadOrStoreClient(key, c)
-	if loaded {
-		c.Close()
+	// 建连期间connector可能已关闭, 或连接池已被clean或retain删除, 需要重新检查以免新连接泄漏
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.done:
+		nc.Close()
+		pl.fail(time.Now(), &p.opts.Backoff)
+		return nil, rpc.ErrShutdown
+	default:
+	}
+	if cur, ok := p.pools[key]; !ok {
+		p.pools[key] = pl
+	} else if cur != pl {
+		cur.add(cn)
+	}
+	pl.ready(cn)
+	return nc, nil
+}
+
+func (p *connector) newClient(network, address string, secure bool) (*rpc.Client, error) {
+	opts := p.opts.Dial
+	if !secure {
+		opts.TLSConfig = nil
+	} else if opts.TLSConfig == nil {
+		opts.TLSConfig = &tls.Config{}
 	}
-	return actual, nil
+	c, err := rpc.DialWithOptions(p.name, network, address, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.RLock()
+	c.SetTraceOutput(p.output)
+	c.SetTraceVerbose(p.verbose)
+	p.mu.RUnlock()
+	return c, nil
 }
 
-func (p *connector) loadClient(key string) (*rpc.Client, bool) {
+// connState 返回endpoint的连接状态
+func (p *connector) connState(key string) ConnState {
 	p.mu.RLock()
-	c, ok := p.clients[key]
+	pl, ok := p.pools[key]
 	p.mu.RUnlock()
-	return c, ok
+	if !ok {
+		return Idle
+	}
+	return pl.connState()
 }
 
-func (p *connector) loadOrStoreClient(key string, c *rpc.Client) (actual *rpc.Client, loaded bool) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
+// maxAge 返回加上随机抖动后的连接最长存活时间
+func (p *connector) maxAge() time.Duration {
+	age := p.opts.MaxConnectionAge
+	jitter := float64(age) * p.opts.MaxConnectionAgeJitter
+	return age + time.Duration(jitter*(2*rand.Float64()-1))
+}
 
-	if oc, ok := p.clients[key]; ok {
-		return oc, true
+func (p *connector) loadPool(key string) *pool {
+	now := time.Now()
+	p.mu.RLock()
+	pl, ok := p.pools[key]
+	p.mu.RUnlock()
+	if !ok {
+		p.mu.Lock()
+		if pl, ok = p.pools[key]; !ok {
+			pl = &pool{}
+			p.pools[key] = pl
+		}
+		p.mu.Unlock()
 	}
-	p.clients[key] = c
-	return c, false
+	pl.use(now)
+	return pl
 }
 
-func (p *connector) deleteClient(key string) {
-	p.mu.Lock()
-	delete(p.clients, key)
-	p.mu.Unlock()
+func (p *connector) loadClients(key string) []*rpc.Client {
+	p.mu.RLock()
+	pl, ok := p.pools[key]
+	p.mu.RUnlock()
+	if !ok {
+		return nil
+	}
+	return pl.clients()
 }
@@ -1,10 +1,16 @@
 package zclient
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"net"
 	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
+	"github.com/ironzhang/zerone/rpc"
 )
 
 func ServeConnector(network, address string) {
@@ -25,7 +31,7 @@ func init() {
 }
 
 func TestConnectorDial(t *testing.T) {
-	c := newConnector("")
+	c := newConnector("", ConnectionOptions{})
 
 	type point struct {
 		key, net, addr string
@@ -64,11 +70,11 @@ func TestConnectorDial(t *testing.T) {
 		},
 	}
 	for i, tt := range tests {
-		c1, err := c.dial(tt.p1.key, tt.p1.net, tt.p1.addr)
+		c1, err := c.dial(tt.p1.key, tt.p1.net, tt.p1.addr, false)
 		if err != nil {
 			t.Fatalf("%d: dial: %v", i, err)
 		}
-		c2, err := c.dial(tt.p2.key, tt.p2.net, tt.p2.addr)
+		c2, err := c.dial(tt.p2.key, tt.p2.net, tt.p2.addr, false)
 		if err != nil {
 			t.Fatalf("%d: dial: %v", i, err)
 		}
@@ -78,17 +84,235 @@ func TestConnectorDial(t *testing.T) {
 	}
 }
 
+func TestConnectorRetain(t *testing.T) {
+	c := newConnector("", ConnectionOptions{})
+	defer c.close()
+
+	eps := []endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:3000"},
+		{Name: "1", Net: "tcp", Addr: "127.0.0.1:3000"},
+	}
+	clients := make([]*rpc.Client, 0, len(eps))
+	for i, ep := range eps {
+		rc, err := c.dial(endpointKey(ep), ep.Net, ep.Addr, false)
+		if err != nil {
+			t.Fatalf("%d: dial: %v", i, err)
+		}
+		clients = append(clients, rc)
+	}
+
+	c.retain(eps[:1])
+	if len(c.loadClients(endpointKey(eps[0]))) <= 0 {
+		t.Errorf("client of retained endpoint is deleted")
+	}
+	if len(c.loadClients(endpointKey(eps[1]))) > 0 {
+		t.Errorf("client of removed endpoint is not deleted")
+	}
+	if !clients[1].IsShutdown() {
+		t.Errorf("client of removed endpoint is not closed")
+	}
+}
+
+func TestClientWatchTable(t *testing.T) {
+	tb := &WatchableTable{}
+	tb.setEndpoints([]endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:3000"},
+		{Name: "1", Net: "tcp", Addr: "127.0.0.1:3000"},
+	})
+	c := New("", tb)
+	defer c.Close()
+
+	for i, ep := range tb.ListEndpoints() {
+		if _, err := c.connector.dial(endpointKey(ep), ep.Net, ep.Addr, false); err != nil {
+			t.Fatalf("%d: dial: %v", i, err)
+		}
+	}
+	tb.setEndpoints(tb.ListEndpoints()[:1])
+	if len(c.connector.loadClients(dialKey("tcp", "127.0.0.1:3000"))) > 0 {
+		t.Errorf("client of removed endpoint is not deleted")
+	}
+}
+
+func TestConnectorClean(t *testing.T) {
+	c := newConnector("", ConnectionOptions{IdleTimeout: time.Minute, MaxConnectionAge: time.Hour})
+	defer c.close()
+
+	eps := []endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:3000"},
+		{Name: "1", Net: "tcp", Addr: "127.0.0.1:3000"},
+	}
+	dial := func(ep endpoint.Endpoint) *rpc.Client {
+		rc, err := c.dial(endpointKey(ep), ep.Net, ep.Addr, false)
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		return rc
+	}
+	loaded := func(ep endpoint.Endpoint, rc *rpc.Client) bool {
+		for _, lc := range c.loadClients(endpointKey(ep)) {
+			if lc == rc {
+				return true
+			}
+		}
+		return false
+	}
+
+	now := time.Now()
+	tests := []struct {
+		used    time.Time
+		now     time.Time
+		eps     []endpoint.Endpoint
+		cleaned bool
+	}{
+		{used: now, now: now, eps: eps, cleaned: false},
+		{used: now, now: now, eps: eps[1:], cleaned: true},                                   // endpoint已删除
+		{used: now, now: now.Add(2 * time.Minute), eps: eps, cleaned: true},                  // 空闲超时
+		{used: now.Add(2 * time.Hour), now: now.Add(2 * time.Hour), eps: eps, cleaned: true}, // 超过最长存活时间
+	}
+	for i, tt := range tests {
+		rc := dial(eps[0])
+		c.loadPool(endpointKey(eps[0])).use(tt.used)
+		c.clean(tt.now, tt.eps)
+		if got, want := !loaded(eps[0], rc), tt.cleaned; got != want {
+			t.Errorf("%d: cleaned: got %v, want %v", i, got, want)
+		}
+		if got, want := rc.IsShutdown(), tt.cleaned; got != want {
+			t.Errorf("%d: shutdown: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestConnectorPool(t *testing.T) {
+	policies := []PoolPolicy{RoundRobinPool, LeastPendingPool}
+	for _, policy := range policies {
+		c := newConnector("", ConnectionOptions{PoolSize: 3, PoolPolicy: policy, PoolScaleThreshold: 1})
+		key := dialKey("tcp", "localhost:4000")
+
+		done := make(chan *rpc.Call, 10)
+		for i := 0; i < 5; i++ {
+			rc, err := c.dial(key, "tcp", "localhost:4000", false)
+			if err != nil {
+				t.Fatalf("%s: dial: %v", policy, err)
+			}
+			if _, err = rc.Go(context.Background(), "Echo.Sleep", 100*time.Millisecond, nil, 0, done); err != nil {
+				t.Fatalf("%s: go: %v", policy, err)
+			}
+		}
+		clients := c.loadClients(key)
+		if got, want := len(clients), 3; got != want {
+			t.Errorf("%s: pool size: got %v, want %v", policy, got, want)
+		}
+		for i, rc := range clients {
+			if rc.Pending() <= 0 {
+				t.Errorf("%s: %d: client has no pending call", policy, i)
+			}
+		}
+		for i := 0; i < 5; i++ {
+			if call := <-done; call.Error != nil {
+				t.Errorf("%s: call: %v", policy, call.Error)
+			}
+		}
+		c.close()
+	}
+}
+
+func TestConnectorDialer(t *testing.T) {
+	dialed := 0
+	opts := ConnectionOptions{
+		Dial: rpc.DialOptions{
+			Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+				dialed++
+				var d net.Dialer
+				return d.DialContext(ctx, network, address)
+			},
+		},
+	}
+	c := newConnector("", opts)
+	defer c.close()
+
+	key := dialKey("tcp", "localhost:4000")
+	if _, err := c.dial(key, "tcp", "localhost:4000", false); err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if got, want := dialed, 1; got != want {
+		t.Errorf("dialed: got %v, want %v", got, want)
+	}
+}
+
+func TestConnectorDialOrphanedPool(t *testing.T) {
+	var c *connector
+	opts := ConnectionOptions{
+		Dial: rpc.DialOptions{
+			Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+				// 建连期间连接池被删除
+				c.retain(nil)
+				var d net.Dialer
+				return d.DialContext(ctx, network, address)
+			},
+		},
+	}
+	c = newConnector("", opts)
+	defer c.close()
+
+	key := dialKey("tcp", "localhost:3000")
+	rc, err := c.dial(key, "tcp", "localhost:3000", false)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	if clients := c.loadClients(key); len(clients) != 1 || clients[0] != rc {
+		t.Errorf("clients: got %v, want [%p]", clients, rc)
+	}
+}
+
+func TestConnectorDialAfterClose(t *testing.T) {
+	var c *connector
+	opts := ConnectionOptions{
+		Dial: rpc.DialOptions{
+			Dialer: func(ctx context.Context, network, address string) (net.Conn, error) {
+				// 建连期间connector被关闭
+				c.close()
+				var d net.Dialer
+				return d.DialContext(ctx, network, address)
+			},
+		},
+	}
+	c = newConnector("", opts)
+
+	key := dialKey("tcp", "localhost:3000")
+	if _, err := c.dial(key, "tcp", "localhost:3000", false); err != rpc.ErrShutdown {
+		t.Errorf("dial: got %v, want %v", err, rpc.ErrShutdown)
+	}
+	if clients := c.loadClients(key); len(clients) != 0 {
+		t.Errorf("clients: got %v, want none", clients)
+	}
+}
+
+type WatchableTable struct {
+	route.Notifier
+	endpoints []endpoint.Endpoint
+}
+
+func (t *WatchableTable) ListEndpoints() []endpoint.Endpoint {
+	return t.endpoints
+}
+
+func (t *WatchableTable) setEndpoints(eps []endpoint.Endpoint) {
+	e := route.Diff(t.endpoints, eps)
+	t.endpoints = eps
+	t.Notify(e)
+}
+
 func BenchmarkConnectorDial(b *testing.B) {
 	b.SetParallelism(50)
-	c := newConnector("")
+	c := newConnector("", ConnectionOptions{})
 	b.RunParallel(func(pb *testing.PB) {
 		key := fmt.Sprint(rand.Int())
 		for pb.Next() {
-			_, err := c.dial(key, "tcp", "localhost:3000")
+			_, err := c.dial(key, "tcp", "localhost:3000", false)
 			if err != nil {
 				b.Fatalf("dial: %v", err)
 			}
 		}
 	})
-	//fmt.Printf("client's num: %d\n", len(c.clients))
+	//fmt.Printf("pool's num: %d\n", len(c.pools))
 }
@@ -11,7 +11,7 @@ import (
 var timeSleep = time.Sleep
 
 type FailPolicy interface {
-	execute(lb balance.LoadBalancer, key []byte, do func(net, addr string) (*rpc.Call, error)) (*rpc.Call, error)
+	execute(lb balance.LoadBalancer, key []byte, do func(ep endpoint.Endpoint) (*rpc.Call, error)) (*rpc.Call, error)
 }
 
 type Failtry struct {
@@ -40,7 +40,7 @@ func NewFailtry(try int, min, max time.Duration) *Failtry {
 	}
 }
 
-func (p *Failtry) execute(lb balance.LoadBalancer, key []byte, do func(net, addr string) (*rpc.Call, error)) (*rpc.Call, error) {
+func (p *Failtry) execute(lb balance.LoadBalancer, key []byte, do func(ep endpoint.Endpoint) (*rpc.Call, error)) (*rpc.Call, error) {
 	ep, err := lb.GetEndpoint(key)
 	if err != nil {
 		return nil, err
@@ -56,7 +56,7 @@ func (p *Failtry) execute(lb balance.LoadBalancer, key []byte, do func(net, addr
 				delay = p.max
 			}
 		}
-		if call, err = do(ep.Net, ep.Addr); err == rpc.ErrShutdown {
+		if call, err = do(ep); err == rpc.ErrShutdown {
 			return nil, err
 		} else if err != nil {
 			continue
@@ -80,7 +80,7 @@ func NewFailover(try int) *Failover {
 	}
 }
 
-func (p *Failover) execute(lb balance.LoadBalancer, key []byte, do func(net, addr string) (*rpc.Call, error)) (*rpc.Call, error) {
+func (p *Failover) execute(lb balance.LoadBalancer, key []byte, do func(ep endpoint.Endpoint) (*rpc.Call, error)) (*rpc.Call, error) {
 	var err error
 	var call *rpc.Call
 	var ep endpoint.Endpoint
@@ -89,7 +89,7 @@ func (p *Failover) execute(lb balance.LoadBalancer, key []byte, do func(net, add
 		if err != nil {
 			return nil, err
 		}
-		if call, err = do(ep.Net, ep.Addr); err == rpc.ErrShutdown {
+		if call, err = do(ep); err == rpc.ErrShutdown {
 			return nil, err
 		} else if err != nil {
 			continue
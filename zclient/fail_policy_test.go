@@ -15,8 +15,8 @@ import (
 
 func TestFailtry(t *testing.T) {
 	tb := stable.NewTable([]endpoint.Endpoint{
-		{"0", "tcp", "localhost:10000", 0},
-		{"1", "tcp", "localhost:10001", 0},
+		{Name: "0", Net: "tcp", Addr: "localhost:10000", Load: 0},
+		{Name: "1", Net: "tcp", Addr: "localhost:10001", Load: 0},
 	})
 
 	var (
@@ -139,9 +139,9 @@ func TestFailtry(t *testing.T) {
 		sleep = 0
 		docnt = 0
 		addrs = nil
-		do := func(net, addr string) (*rpc.Call, error) {
+		do := func(ep endpoint.Endpoint) (*rpc.Call, error) {
 			docnt++
-			addrs = append(addrs, fmt.Sprintf("%s://%s", net, addr))
+			addrs = append(addrs, fmt.Sprintf("%s://%s", ep.Net, ep.Addr))
 			return nil, tt.err
 		}
 
@@ -163,8 +163,8 @@ func TestFailtry(t *testing.T) {
 
 func TestFailover(t *testing.T) {
 	tb := stable.NewTable([]endpoint.Endpoint{
-		{"0", "tcp", "localhost:10000", 0},
-		{"1", "tcp", "localhost:10001", 0},
+		{Name: "0", Net: "tcp", Addr: "localhost:10000", Load: 0},
+		{Name: "1", Net: "tcp", Addr: "localhost:10001", Load: 0},
 	})
 
 	var (
@@ -225,9 +225,9 @@ func TestFailover(t *testing.T) {
 	for i, tt := range tests {
 		docnt = 0
 		addrs = nil
-		do := func(net, addr string) (*rpc.Call, error) {
+		do := func(ep endpoint.Endpoint) (*rpc.Call, error) {
 			docnt++
-			addrs = append(addrs, fmt.Sprintf("%s://%s", net, addr))
+			addrs = append(addrs, fmt.Sprintf("%s://%s", ep.Net, ep.Addr))
 			return nil, tt.err
 		}
 
@@ -0,0 +1,190 @@
+package zclient
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route"
+	"github.com/ironzhang/zerone/rpc"
+)
+
+// HealthCheckOptions 主动健康检查选项
+type HealthCheckOptions struct {
+	Interval           time.Duration // 检查间隔, 为0时不做健康检查
+	Timeout            time.Duration // 单次检查超时时间, 默认为Interval
+	HealthyThreshold   int           // 连续成功多少次后恢复endpoint, 默认为2
+	UnhealthyThreshold int           // 连续失败多少次后摘除endpoint, 默认为3
+}
+
+// EndpointStatus endpoint的健康状态
+type EndpointStatus struct {
+	Endpoint  endpoint.Endpoint
+	Healthy   bool
+	LastCheck time.Time
+	LastError error
+	State     ConnState
+}
+
+type healthState struct {
+	healthy   bool
+	successes int
+	failures  int
+	lastCheck time.Time
+	lastError error
+}
+
+func (s *healthState) update(healthy, unhealthy int, now time.Time, err error) {
+	s.lastCheck = now
+	s.lastError = err
+	if err == nil {
+		s.failures = 0
+		s.successes++
+		if !s.healthy && s.successes >= healthy {
+			s.healthy = true
+		}
+	} else {
+		s.successes = 0
+		s.failures++
+		if s.healthy && s.failures >= unhealthy {
+			s.healthy = false
+		}
+	}
+}
+
+var _ route.Table = &healthChecker{}
+
+// healthChecker 定期通过rpc.HealthCheckMethod探测endpoint, 并从路由表中摘除不健康的endpoint
+type healthChecker struct {
+	table     route.Table
+	connector *connector
+	opts      HealthCheckOptions
+	done      chan struct{}
+
+	mu     sync.RWMutex
+	states map[string]*healthState
+}
+
+func newHealthChecker(table route.Table, connector *connector, opts HealthCheckOptions) *healthChecker {
+	if opts.Timeout <= 0 {
+		opts.Timeout = opts.Interval
+	}
+	if opts.HealthyThreshold <= 0 {
+		opts.HealthyThreshold = 2
+	}
+	if opts.UnhealthyThreshold <= 0 {
+		opts.UnhealthyThreshold = 3
+	}
+	h := &healthChecker{
+		table:     table,
+		connector: connector,
+		opts:      opts,
+		done:      make(chan struct{}),
+		states:    make(map[string]*healthState),
+	}
+	go h.checking()
+	return h
+}
+
+func (h *healthChecker) close() {
+	close(h.done)
+}
+
+func (h *healthChecker) ListEndpoints() []endpoint.Endpoint {
+	eps := h.table.ListEndpoints()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	res := make([]endpoint.Endpoint, 0, len(eps))
+	for _, ep := range eps {
+		if s, ok := h.states[endpointKey(ep)]; ok && !s.healthy {
+			continue
+		}
+		res = append(res, ep)
+	}
+	return res
+}
+
+func (h *healthChecker) listEndpointStatuses() []EndpointStatus {
+	eps := h.table.ListEndpoints()
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	res := make([]EndpointStatus, 0, len(eps))
+	for _, ep := range eps {
+		st := EndpointStatus{Endpoint: ep, Healthy: true}
+		if s, ok := h.states[endpointKey(ep)]; ok {
+			st.Healthy = s.healthy
+			st.LastCheck = s.lastCheck
+			st.LastError = s.lastError
+		}
+		res = append(res, st)
+	}
+	return res
+}
+
+func (h *healthChecker) checking() {
+	t := time.NewTicker(h.opts.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			h.checkAll()
+		case <-h.done:
+			return
+		}
+	}
+}
+
+func (h *healthChecker) checkAll() {
+	var wg sync.WaitGroup
+	eps := h.table.ListEndpoints()
+	keys := make(map[string]bool, len(eps))
+	for _, ep := range eps {
+		key := endpointKey(ep)
+		if keys[key] {
+			continue
+		}
+		keys[key] = true
+
+		wg.Add(1)
+		go func(key string, ep endpoint.Endpoint) {
+			defer wg.Done()
+			err := h.check(key, ep)
+			h.update(key, err)
+		}(key, ep)
+	}
+	wg.Wait()
+
+	// 清理已不在路由表中的endpoint
+	h.mu.Lock()
+	for key := range h.states {
+		if !keys[key] {
+			delete(h.states, key)
+		}
+	}
+	h.mu.Unlock()
+}
+
+func (h *healthChecker) check(key string, ep endpoint.Endpoint) error {
+	rc, err := h.connector.dial(key, ep.Net, ep.Addr, ep.TLS)
+	if err != nil {
+		return err
+	}
+	var status string
+	return rc.Call(context.Background(), rpc.HealthCheckMethod, nil, &status, h.opts.Timeout)
+}
+
+func (h *healthChecker) update(key string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.states[key]
+	if !ok {
+		s = &healthState{healthy: true}
+		h.states[key] = s
+	}
+	s.update(h.opts.HealthyThreshold, h.opts.UnhealthyThreshold, time.Now(), err)
+}
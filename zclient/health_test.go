@@ -0,0 +1,77 @@
+package zclient
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route/stable"
+)
+
+func TestHealthStateUpdate(t *testing.T) {
+	tests := []struct {
+		errs    []error
+		healthy bool
+	}{
+		{errs: nil, healthy: true},
+		{errs: []error{io.EOF}, healthy: true},
+		{errs: []error{io.EOF, io.EOF}, healthy: false},
+		{errs: []error{io.EOF, io.EOF, nil}, healthy: false},
+		{errs: []error{io.EOF, io.EOF, nil, nil}, healthy: true},
+		{errs: []error{io.EOF, nil, io.EOF}, healthy: true},
+		{errs: []error{io.EOF, io.EOF, nil, io.EOF, nil}, healthy: false},
+	}
+	for i, tt := range tests {
+		s := &healthState{healthy: true}
+		for _, err := range tt.errs {
+			s.update(2, 2, time.Now(), err)
+		}
+		if got, want := s.healthy, tt.healthy; got != want {
+			t.Errorf("%d: healthy: got %v, want %v", i, got, want)
+		}
+	}
+}
+
+func WaitHealthy(c *Client, n int) error {
+	for i := 0; i < 200; i++ {
+		time.Sleep(10 * time.Millisecond)
+		if len(c.available.ListEndpoints()) == n {
+			return nil
+		}
+	}
+	return errors.New("timeout")
+}
+
+func TestHealthCheck(t *testing.T) {
+	tb := stable.NewTable([]endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:4000"},
+		{Name: "1", Net: "tcp", Addr: "localhost:4001"},
+	})
+	c := NewWithOptions("Client", tb, Options{
+		HealthCheck: HealthCheckOptions{
+			Interval:           10 * time.Millisecond,
+			HealthyThreshold:   1,
+			UnhealthyThreshold: 1,
+		},
+	})
+	defer c.Close()
+
+	if err := WaitHealthy(c, 1); err != nil {
+		t.Fatalf("wait unhealthy: %v", err)
+	}
+	for _, st := range c.ListEndpointStatuses() {
+		if got, want := st.Healthy, st.Endpoint.Name == "0"; got != want {
+			t.Errorf("%s: healthy: got %v, want %v", st.Endpoint.Name, got, want)
+		} else {
+			t.Logf("%s: healthy: got %v, last error: %v", st.Endpoint.Name, got, st.LastError)
+		}
+	}
+
+	ln := ServeEcho("tcp", "localhost:4001")
+	defer ln.Close()
+	if err := WaitHealthy(c, 2); err != nil {
+		t.Fatalf("wait healthy: %v", err)
+	}
+}
@@ -0,0 +1,20 @@
+package zclient
+
+import (
+	"github.com/ironzhang/zerone/rpc"
+	"github.com/ironzhang/zerone/rpc/codes"
+)
+
+// isOutlierFailure 判断调用结果是否计入endpoint的失败: 连接不可用、超时、服务端内部错误及拨号失败等网络错误
+func isOutlierFailure(err error) bool {
+	switch err {
+	case nil, rpc.ErrShutdown:
+		return false
+	case rpc.ErrUnavailable, rpc.ErrTimeout:
+		return true
+	}
+	if e, ok := err.(rpc.ErrorCode); ok {
+		return e.Code() == codes.Internal
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package zclient
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route/outlier"
+	"github.com/ironzhang/zerone/pkg/route/stable"
+	"github.com/ironzhang/zerone/rpc"
+	"github.com/ironzhang/zerone/rpc/codes"
+)
+
+func TestIsOutlierFailure(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: nil, want: false},
+		{err: rpc.ErrShutdown, want: false},
+		{err: rpc.ErrUnavailable, want: true},
+		{err: rpc.ErrTimeout, want: true},
+		{err: io.EOF, want: true},
+		{err: rpc.NewError(codes.Internal, errors.New("internal")), want: true},
+		{err: rpc.NewError(codes.Unknown, errors.New("divide by zero")), want: false},
+		{err: rpc.NewError(codes.InvalidRequest, errors.New("invalid")), want: false},
+	}
+	for i, tt := range tests {
+		if got, want := isOutlierFailure(tt.err), tt.want; got != want {
+			t.Errorf("%d: %v: got %v, want %v", i, tt.err, got, want)
+		}
+	}
+}
+
+func TestClientOutlier(t *testing.T) {
+	tb := stable.NewTable([]endpoint.Endpoint{
+		{Name: "0", Net: "tcp", Addr: "localhost:4000"},
+		{Name: "1", Net: "tcp", Addr: "localhost:4999"},
+	})
+	c := NewWithOptions("Client", tb, Options{
+		Outlier: &outlier.Options{ConsecutiveFailures: 1, BaseEjectionTime: time.Minute},
+	})
+	defer c.Close()
+	c = c.WithBalancePolicy(RoundRobinBalancer)
+
+	var reply string
+	for i := 0; i < 10; i++ {
+		err := c.Call(context.Background(), nil, "Echo.Echo", "hello", &reply, 0)
+		if i > 1 && err != nil {
+			t.Fatalf("%d: call: %v", i, err)
+		}
+	}
+	if got, want := len(c.available.ListEndpoints()), 1; got != want {
+		t.Fatalf("available endpoints: got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,72 @@
+package zclient
+
+import (
+	"sync"
+
+	"github.com/ironzhang/zerone/pkg/balance"
+	"github.com/ironzhang/zerone/pkg/route"
+	"github.com/ironzhang/zerone/pkg/route/split"
+)
+
+// splitter 为每个路由子集维护独立的负载均衡器
+type splitter struct {
+	table  route.Table
+	router *split.Router
+
+	mu       sync.Mutex
+	balances map[string]*balance.Manager
+}
+
+func newSplitter(table route.Table, router *split.Router) *splitter {
+	return &splitter{
+		table:    table,
+		router:   router,
+		balances: make(map[string]*balance.Manager),
+	}
+}
+
+func (p *splitter) getLoadBalancer(key []byte, policy string) (string, balance.LoadBalancer, bool) {
+	subset := p.router.Pick(key)
+	if subset == "" {
+		return "", nil, false
+	}
+
+	p.mu.Lock()
+	m, ok := p.balances[subset]
+	if !ok {
+		m = balance.NewManager(split.NewTable(p.table, p.router, subset), nil)
+		p.balances[subset] = m
+	}
+	p.mu.Unlock()
+	return subset, m.GetLoadBalancer(policy), true
+}
+
+func (p *splitter) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, m := range p.balances {
+		m.Close()
+	}
+	p.balances = make(map[string]*balance.Manager)
+}
+
+// splitters 记录Client及其副本创建的splitter, Client关闭时一起关闭
+type splitters struct {
+	mu   sync.Mutex
+	list []*splitter
+}
+
+func (p *splitters) add(s *splitter) {
+	p.mu.Lock()
+	p.list = append(p.list, s)
+	p.mu.Unlock()
+}
+
+func (p *splitters) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, s := range p.list {
+		s.close()
+	}
+	p.list = nil
+}
@@ -1,6 +1,23 @@
 package zclient
 
-import "reflect"
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+)
+
+func dialKey(net, addr string) string {
+	return fmt.Sprintf("%s://%s", net, addr)
+}
+
+// endpointKey 返回endpoint的连接键, TLS与非TLS连接使用不同的键
+func endpointKey(ep endpoint.Endpoint) string {
+	if ep.TLS {
+		return dialKey(ep.Net+"+tls", ep.Addr)
+	}
+	return dialKey(ep.Net, ep.Addr)
+}
 
 func newValuePtr(a interface{}) interface{} {
 	if a == nil {
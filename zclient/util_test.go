@@ -3,6 +3,8 @@ package zclient
 import (
 	"reflect"
 	"testing"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
 )
 
 func TestNewValuePtr(t *testing.T) {
@@ -31,3 +33,19 @@ func TestNewValuePtr(t *testing.T) {
 		}
 	}
 }
+
+func TestEndpointKey(t *testing.T) {
+	tests := []struct {
+		ep  endpoint.Endpoint
+		key string
+	}{
+		{ep: endpoint.Endpoint{Net: "tcp", Addr: "localhost:2000"}, key: "tcp://localhost:2000"},
+		{ep: endpoint.Endpoint{Net: "tcp", Addr: "localhost:2000", TLS: true}, key: "tcp+tls://localhost:2000"},
+		{ep: endpoint.Endpoint{Net: "unix", Addr: "/tmp/zerone.sock"}, key: "unix:///tmp/zerone.sock"},
+	}
+	for i, tt := range tests {
+		if got, want := endpointKey(tt.ep), tt.key; got != want {
+			t.Errorf("%d: got %v, want %v", i, got, want)
+		}
+	}
+}
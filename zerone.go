@@ -2,20 +2,35 @@ package zerone
 
 import (
 	"fmt"
+	"os"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/ironzhang/x-pearls/govern"
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route/ctable"
+	"github.com/ironzhang/zerone/pkg/route/dnstable"
 	"github.com/ironzhang/zerone/pkg/route/dtable"
+	"github.com/ironzhang/zerone/pkg/route/split"
 	"github.com/ironzhang/zerone/pkg/route/stable"
 	"github.com/ironzhang/zerone/zclient"
 	"github.com/ironzhang/zerone/zserver"
 )
 
 type SOptions struct {
-	Filename string
+	Filename       string
+	WatchInterval  time.Duration // 路由文件变更检查间隔, 为0时不检查
+	ReloadOnSIGHUP bool          // 收到SIGHUP信号时重新加载路由文件
+	ClientOptions  zclient.Options
 }
 
 type SZerone struct {
-	tables stable.Tables
+	tables *stable.Watcher
+	split  *split.FileSource
+	reload bool // 路由文件是否会热加载
+	copts  zclient.Options
+	once   sync.Once
 }
 
 func NewSZerone(opts SOptions) (*SZerone, error) {
@@ -24,14 +39,33 @@ func NewSZerone(opts SOptions) (*SZerone, error) {
 
 func (p *SZerone) Init(opts SOptions) (*SZerone, error) {
 	var err error
-	if p.tables, err = stable.LoadTables(opts.Filename); err != nil {
+	var signals []os.Signal
+	if opts.ReloadOnSIGHUP {
+		signals = append(signals, syscall.SIGHUP)
+	}
+	if p.tables, err = stable.NewWatcher(opts.Filename, opts.WatchInterval, signals...); err != nil {
+		return nil, err
+	}
+	if p.split, err = split.NewFileSource(p.tables); err != nil {
+		p.tables.Close()
 		return nil, err
 	}
+	p.reload = opts.WatchInterval > 0 || opts.ReloadOnSIGHUP
+	p.copts = opts.ClientOptions
 	return p, nil
 }
 
-func (p *SZerone) Close() error {
-	return nil
+// splits 判断服务是否需要分流, 路由文件配置了切分规则, 或热加载后可能新增切分规则
+func (p *SZerone) splits(service string) bool {
+	return p.reload || p.split.HasRules(service)
+}
+
+func (p *SZerone) Close() (err error) {
+	p.once.Do(func() {
+		p.split.Close()
+		err = p.tables.Close()
+	})
+	return err
 }
 
 func (p *SZerone) NewClient(name, service string) (*zclient.Client, error) {
@@ -39,7 +73,11 @@ func (p *SZerone) NewClient(name, service string) (*zclient.Client, error) {
 	if err != nil {
 		return nil, err
 	}
-	return zclient.New(name, tb), nil
+	c := zclient.NewWithOptions(name, tb, p.copts)
+	if p.splits(service) {
+		c = c.WithRouter(p.split.Router(service))
+	}
+	return c, nil
 }
 
 func (p *SZerone) NewServer(name, service string) (*zserver.Server, error) {
@@ -50,10 +88,22 @@ type DOptions struct {
 	Namespace string
 	Driver    string
 	Config    interface{}
+	Split     bool // 是否从服务治理中订阅切分规则
+
+	AdvertiseAddress string                  // 服务注册的地址, 参见zserver.Server.SetAdvertiseAddress
+	Register         zserver.RegisterOptions // 服务注册选项
+	Table            dtable.Options          // 路由表选项, 如本地缓存
+
+	ClientOptions zclient.Options
 }
 
 type DZerone struct {
-	driver govern.Driver
+	driver    govern.Driver
+	split     *split.DriverSource
+	copts     zclient.Options
+	advertise string
+	ropts     zserver.RegisterOptions
+	topts     dtable.Options
 }
 
 func NewDZerone(opts DOptions) (*DZerone, error) {
@@ -65,20 +115,181 @@ func (p *DZerone) Init(opts DOptions) (*DZerone, error) {
 	if p.driver, err = govern.Open(opts.Driver, opts.Namespace, opts.Config); err != nil {
 		return nil, err
 	}
+	if opts.Split {
+		p.split = split.NewDriverSource(p.driver)
+	}
+	p.copts = opts.ClientOptions
+	p.advertise = opts.AdvertiseAddress
+	p.ropts = opts.Register
+	p.topts = opts.Table
 	return p, nil
 }
 
 func (p *DZerone) Close() error {
+	if p.split != nil {
+		p.split.Close()
+	}
 	return p.driver.Close()
 }
 
 func (p *DZerone) NewClient(name, service string) (*zclient.Client, error) {
-	tb := dtable.NewTable(p.driver, service)
-	return zclient.New(name, tb), nil
+	c := zclient.NewWithOptions(name, p.newTable(service), p.copts)
+	if p.split != nil {
+		c = c.WithRouter(p.split.Router(service))
+	}
+	return c, nil
+}
+
+func (p *DZerone) newTable(service string) *dtable.Table {
+	return dtable.NewTableWithOptions(p.driver, service, p.topts)
 }
 
 func (p *DZerone) NewServer(name, service string) (*zserver.Server, error) {
-	return zserver.New(name, service, p.driver), nil
+	s := zserver.New(name, service, p.driver)
+	if p.advertise != "" {
+		s.SetAdvertiseAddress(p.advertise)
+	}
+	s.SetRegisterOptions(p.ropts)
+	return s, nil
+}
+
+type DNSOptions struct {
+	Records  map[string]dnstable.Record // 服务名到DNS记录的映射
+	Interval time.Duration              // DNS刷新间隔, 默认为30s
+	Resolver dnstable.Resolver          // DNS解析器, 为nil时使用net.DefaultResolver
+
+	ClientOptions zclient.Options
+}
+
+type DNSZerone struct {
+	opts DNSOptions
+}
+
+func NewDNSZerone(opts DNSOptions) (*DNSZerone, error) {
+	return new(DNSZerone).Init(opts)
+}
+
+func (p *DNSZerone) Init(opts DNSOptions) (*DNSZerone, error) {
+	p.opts = opts
+	return p, nil
+}
+
+func (p *DNSZerone) Close() error {
+	return nil
+}
+
+func (p *DNSZerone) NewClient(name, service string) (*zclient.Client, error) {
+	record, ok := p.opts.Records[service]
+	if !ok {
+		return nil, fmt.Errorf("service(%s) dns record not found", service)
+	}
+	tb := dnstable.NewTable(record, p.opts.Interval, p.opts.Resolver)
+	return zclient.NewWithOptions(name, tb, p.opts.ClientOptions), nil
+}
+
+func (p *DNSZerone) NewServer(name, service string) (*zserver.Server, error) {
+	return zserver.New(name, service, nil), nil
+}
+
+// RouteSource 服务的路由来源
+type RouteSource string
+
+// 路由来源常量定义
+const (
+	StaticRoute   RouteSource = "static"   // 只使用静态路由文件
+	DynamicRoute  RouteSource = "dynamic"  // 只使用服务发现
+	MergedRoute   RouteSource = "merged"   // 合并静态路由和服务发现, 同名endpoint以静态路由为准
+	FallbackRoute RouteSource = "fallback" // 优先使用服务发现, 服务发现没有endpoint时使用静态路由
+)
+
+// HOptions 混合路由选项, 按服务选择静态路由或服务发现
+type HOptions struct {
+	Static   SOptions
+	Dynamic  DOptions
+	Services map[string]RouteSource // 服务的路由来源
+	Default  RouteSource            // 未配置的服务的路由来源, 默认为DynamicRoute
+
+	// 合并路由时修改各来源的endpoint, 如设置Tags或Load区分来源, 为nil时不修改
+	StaticOverride  func(ep endpoint.Endpoint) endpoint.Endpoint
+	DynamicOverride func(ep endpoint.Endpoint) endpoint.Endpoint
+}
+
+// HZerone 混合路由, 服务端注册到服务发现, 客户端按服务选择路由来源
+type HZerone struct {
+	static  *SZerone
+	dynamic *DZerone
+	opts    HOptions
+}
+
+func NewHZerone(opts HOptions) (*HZerone, error) {
+	return new(HZerone).Init(opts)
+}
+
+func (p *HZerone) Init(opts HOptions) (*HZerone, error) {
+	var err error
+	if p.static, err = NewSZerone(opts.Static); err != nil {
+		return nil, err
+	}
+	if p.dynamic, err = NewDZerone(opts.Dynamic); err != nil {
+		p.static.Close()
+		return nil, err
+	}
+	if opts.Default == "" {
+		opts.Default = DynamicRoute
+	}
+	p.opts = opts
+	return p, nil
+}
+
+func (p *HZerone) Close() error {
+	p.static.Close()
+	return p.dynamic.Close()
+}
+
+func (p *HZerone) NewClient(name, service string) (*zclient.Client, error) {
+	source, ok := p.opts.Services[service]
+	if !ok {
+		source = p.opts.Default
+	}
+
+	var opts ctable.Options
+	switch source {
+	case StaticRoute:
+		return p.static.NewClient(name, service)
+	case DynamicRoute:
+		return p.dynamic.NewClient(name, service)
+	case MergedRoute:
+		opts.Mode = ctable.Merge
+	case FallbackRoute:
+		opts.Mode = ctable.Fallback
+	default:
+		return nil, fmt.Errorf("service(%s) unknown %q route source", service, source)
+	}
+
+	// 静态路由文件中没有该服务时只使用服务发现, 服务发现的路由表由合并路由表持有
+	var sources []ctable.Source
+	dynamic := ctable.Source{Table: p.dynamic.newTable(service), Override: p.opts.DynamicOverride, Owned: true}
+	if tb, err := p.static.tables.Lookup(service); err == nil {
+		static := ctable.Source{Table: tb, Override: p.opts.StaticOverride}
+		if source == MergedRoute {
+			sources = []ctable.Source{static, dynamic}
+		} else {
+			sources = []ctable.Source{dynamic, static}
+		}
+	} else {
+		sources = []ctable.Source{dynamic}
+	}
+	c := zclient.NewWithOptions(name, ctable.NewTable(opts, sources...), p.dynamic.copts)
+	if p.dynamic.split != nil {
+		c = c.WithRouter(p.dynamic.split.Router(service))
+	} else if p.static.splits(service) {
+		c = c.WithRouter(p.static.split.Router(service))
+	}
+	return c, nil
+}
+
+func (p *HZerone) NewServer(name, service string) (*zserver.Server, error) {
+	return p.dynamic.NewServer(name, service)
 }
 
 type Options interface{}
@@ -99,6 +310,14 @@ func NewZerone(opts Options) (Zerone, error) {
 		return NewDZerone(o)
 	case *DOptions:
 		return NewDZerone(*o)
+	case DNSOptions:
+		return NewDNSZerone(o)
+	case *DNSOptions:
+		return NewDNSZerone(*o)
+	case HOptions:
+		return NewHZerone(o)
+	case *HOptions:
+		return NewHZerone(*o)
 	default:
 		return nil, fmt.Errorf("unknown %T options type", opts)
 	}
@@ -0,0 +1,246 @@
+package zerone
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/govern/memory"
+	"github.com/ironzhang/zerone/pkg/registry"
+	"github.com/ironzhang/zerone/zserver"
+)
+
+type Echo struct{}
+
+func (Echo) Echo(ctx context.Context, req string, reply *string) error {
+	*reply = req
+	return nil
+}
+
+func TestDZeroneWithMemoryDriver(t *testing.T) {
+	z, err := NewZerone(DOptions{Namespace: "TestDZerone", Driver: memory.DriverName})
+	if err != nil {
+		t.Fatalf("new zerone: %v", err)
+	}
+	defer z.Close()
+
+	s, err := z.NewServer("TestServer", "Echo")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	defer s.Close()
+	if err = s.Register(Echo{}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	go s.ListenAndServe("tcp", "localhost:0", "")
+
+	c, err := z.NewClient("TestClient", "Echo")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer c.Close()
+
+	req, reply := "hello", ""
+	for i := 0; i < 100; i++ {
+		if err = c.Call(context.Background(), nil, "Echo.Echo", req, &reply, time.Second); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got, want := reply, req; got != want {
+		t.Errorf("reply: got %v, want %v", got, want)
+	}
+}
+
+func TestDZeroneWithRegistryDriver(t *testing.T) {
+	r := registry.New()
+	defer r.Close()
+	rs := zserver.New("TestRegistry", "TestRegistry", nil)
+	defer rs.Close()
+	if err := rs.Register(r); err != nil {
+		t.Fatalf("register registry: %v", err)
+	}
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go rs.Serve(ln, "")
+
+	cfg := registry.Config{Address: ln.Addr().String(), WatchTimeout: time.Second}
+	z, err := NewZerone(DOptions{Namespace: "TestDZerone", Driver: registry.DriverName, Config: cfg})
+	if err != nil {
+		t.Fatalf("new zerone: %v", err)
+	}
+	defer z.Close()
+
+	s, err := z.NewServer("TestServer", "Echo")
+	if err != nil {
+		t.Fatalf("new server: %v", err)
+	}
+	if err = s.Register(Echo{}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	go s.ListenAndServe("tcp", "localhost:0", "")
+
+	c, err := z.NewClient("TestClient", "Echo")
+	if err != nil {
+		t.Fatalf("new client: %v", err)
+	}
+	defer c.Close()
+
+	req, reply := "hello", ""
+	for i := 0; i < 100; i++ {
+		if err = c.Call(context.Background(), nil, "Echo.Echo", req, &reply, time.Second); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got, want := reply, req; got != want {
+		t.Errorf("reply: got %v, want %v", got, want)
+	}
+
+	// 服务端关闭后从注册中心注销
+	s.Close()
+	for i := 0; i < 100 && len(c.ListEndpoints()) != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := len(c.ListEndpoints()), 0; got != want {
+		t.Errorf("endpoints after close: got %v, want %v", got, want)
+	}
+}
+
+func TestSZerone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zerone")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	routes := `{
+	"Arith": {
+		"Endpoints": [{"Name": "S1", "Net": "tcp", "Addr": "localhost:8000", "Tags": {"version": "v2"}}],
+		"Split": [{"Name": "canary", "Tags": {"version": "v2"}, "Percent": 10}]
+	},
+	"Echo": [{"Name": "S1", "Net": "tcp", "Addr": "localhost:8000"}]
+}`
+	filename := filepath.Join(dir, "route.json")
+	if err = ioutil.WriteFile(filename, []byte(routes), 0644); err != nil {
+		t.Fatalf("write route file: %v", err)
+	}
+
+	z, err := NewSZerone(SOptions{Filename: filename})
+	if err != nil {
+		t.Fatalf("new szerone: %v", err)
+	}
+	if got, want := len(z.split.Router("Arith").Rules()), 1; got != want {
+		t.Errorf("rules: got %v, want %v", got, want)
+	}
+	// 路由文件不热加载时, 只有配置了切分规则的服务分流
+	if !z.splits("Arith") {
+		t.Errorf("Arith: splits is false")
+	}
+	if z.splits("Echo") {
+		t.Errorf("Echo: splits is true")
+	}
+	if err = z.Close(); err != nil {
+		t.Errorf("close: %v", err)
+	}
+	if err = z.Close(); err != nil {
+		t.Errorf("close again: %v", err)
+	}
+}
+
+func TestHZerone(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zerone")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	routes := `{
+	"Static": [{"Name": "S1", "Net": "tcp", "Addr": "localhost:8000"}],
+	"Merged": [{"Name": "S1", "Net": "tcp", "Addr": "localhost:8000"}],
+	"Fallback": [{"Name": "S1", "Net": "tcp", "Addr": "localhost:8000"}]
+}`
+	filename := filepath.Join(dir, "route.json")
+	if err = ioutil.WriteFile(filename, []byte(routes), 0644); err != nil {
+		t.Fatalf("write route file: %v", err)
+	}
+
+	z, err := NewZerone(HOptions{
+		Static:  SOptions{Filename: filename},
+		Dynamic: DOptions{Namespace: "TestHZerone", Driver: memory.DriverName},
+		Services: map[string]RouteSource{
+			"Static":   StaticRoute,
+			"Merged":   MergedRoute,
+			"Fallback": FallbackRoute,
+		},
+		StaticOverride: func(ep endpoint.Endpoint) endpoint.Endpoint {
+			ep.Tags = endpoint.NewTags(map[string]string{"source": "static"})
+			return ep
+		},
+	})
+	if err != nil {
+		t.Fatalf("new zerone: %v", err)
+	}
+	defer z.Close()
+
+	// 每个服务都在服务发现中注册了S1和S2
+	for _, service := range []string{"Static", "Dynamic", "Merged", "Fallback"} {
+		for _, name := range []string{"S1", "S2"} {
+			s, err := z.NewServer(name, service)
+			if err != nil {
+				t.Fatalf("new server: %v", err)
+			}
+			defer s.Close()
+			go s.ListenAndServe("tcp", "localhost:0", name)
+		}
+	}
+
+	tests := []struct {
+		service string
+		addrs   map[string]bool // endpoint名称到是否为静态路由地址的映射
+	}{
+		{service: "Static", addrs: map[string]bool{"S1": true}},
+		{service: "Dynamic", addrs: map[string]bool{"S1": false, "S2": false}},
+		{service: "Merged", addrs: map[string]bool{"S1": true, "S2": false}},
+		{service: "Fallback", addrs: map[string]bool{"S1": false, "S2": false}},
+	}
+	for _, tt := range tests {
+		c, err := z.NewClient("TestClient", tt.service)
+		if err != nil {
+			t.Fatalf("%s: new client: %v", tt.service, err)
+		}
+		defer c.Close()
+
+		statuses := c.ListEndpointStatuses()
+		for i := 0; i < 100 && len(statuses) != len(tt.addrs); i++ {
+			time.Sleep(10 * time.Millisecond)
+			statuses = c.ListEndpointStatuses()
+		}
+		if got, want := len(statuses), len(tt.addrs); got != want {
+			t.Errorf("%s: endpoints: got %v, want %v", tt.service, got, want)
+		}
+		for _, st := range statuses {
+			static, ok := tt.addrs[st.Endpoint.Name]
+			if !ok {
+				t.Errorf("%s: unexpected endpoint %v", tt.service, st.Endpoint)
+			} else if got := st.Endpoint.Addr == "localhost:8000"; got != static {
+				t.Errorf("%s: %s: static: got %v, want %v", tt.service, st.Endpoint.Name, got, static)
+			} else if got, want := st.Endpoint.Match(map[string]string{"source": "static"}), static && tt.service != "Static"; got != want {
+				t.Errorf("%s: %s: override: got %v, want %v", tt.service, st.Endpoint.Name, got, want)
+			}
+		}
+	}
+}
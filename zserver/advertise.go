@@ -0,0 +1,83 @@
+package zserver
+
+import (
+	"net"
+	"os"
+)
+
+// AdvertiseEnv 指定注册地址的环境变量, 未调用SetAdvertiseAddress时生效
+const AdvertiseEnv = "ZERONE_ADVERTISE_ADDR"
+
+// SetAdvertiseAddress 设置注册到服务发现的地址, 与监听地址分离, 用于NAT, 容器等场景.
+// addr为主机名或IP时, 端口取实际监听的端口; addr带端口时原样注册, 只适用于单个监听器.
+// 未设置时依次取环境变量AdvertiseEnv, 监听地址的主机部分, 监听地址未指定主机时自动选择第一个非回环网卡的IP
+func (s *Server) SetAdvertiseAddress(addr string) {
+	s.mu.Lock()
+	s.advertise = addr
+	s.mu.Unlock()
+}
+
+// advertiseAddress 返回监听地址address对应的注册地址, 只处理TCP网络
+func (s *Server) advertiseAddress(network, address string) string {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return address
+	}
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+
+	s.mu.Lock()
+	advertise := s.advertise
+	s.mu.Unlock()
+	if advertise == "" {
+		advertise = os.Getenv(AdvertiseEnv)
+	}
+	if advertise != "" {
+		if _, _, err := net.SplitHostPort(advertise); err == nil {
+			return advertise
+		}
+		return net.JoinHostPort(advertise, port)
+	}
+
+	if ip := net.ParseIP(host); host != "" && (ip == nil || !ip.IsUnspecified()) {
+		return address
+	}
+	if ip := interfaceIP(); ip != nil {
+		return net.JoinHostPort(ip.String(), port)
+	}
+	return address
+}
+
+// interfaceIP 返回第一个已启用的非回环网卡的IP, 优先IPv4
+func interfaceIP() net.IP {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+	var ipv6 net.IP
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipnet, ok := a.(*net.IPNet)
+			if !ok || ipnet.IP.IsLoopback() || ipnet.IP.IsLinkLocalUnicast() {
+				continue
+			}
+			if ip4 := ipnet.IP.To4(); ip4 != nil {
+				return ip4
+			}
+			if ipv6 == nil {
+				ipv6 = ipnet.IP
+			}
+		}
+	}
+	return ipv6
+}
@@ -0,0 +1,42 @@
+package zserver
+
+import (
+	"net"
+	"os"
+	"testing"
+)
+
+func TestAdvertiseAddress(t *testing.T) {
+	os.Unsetenv(AdvertiseEnv)
+	auto := "0.0.0.0:8000"
+	if ip := interfaceIP(); ip != nil {
+		auto = net.JoinHostPort(ip.String(), "8000")
+	}
+
+	tests := []struct {
+		advertise string
+		env       string
+		network   string
+		address   string
+		want      string
+	}{
+		{network: "tcp", address: "localhost:8000", want: "localhost:8000"},
+		{network: "tcp", address: "0.0.0.0:8000", want: auto},
+		{network: "tcp", address: "[::]:8000", want: auto},
+		{network: "tcp", address: ":8000", want: auto},
+		{advertise: "10.0.0.1", network: "tcp", address: ":8000", want: "10.0.0.1:8000"},
+		{advertise: "example.com:80", network: "tcp", address: ":8000", want: "example.com:80"},
+		{env: "10.0.0.2", network: "tcp", address: ":8000", want: "10.0.0.2:8000"},
+		{advertise: "10.0.0.1", env: "10.0.0.2", network: "tcp", address: ":8000", want: "10.0.0.1:8000"},
+		{advertise: "10.0.0.1", network: "unix", address: "/tmp/echo.sock", want: "/tmp/echo.sock"},
+	}
+	for i, tt := range tests {
+		s := New("TestServer-0", "TestServer", nil)
+		s.SetAdvertiseAddress(tt.advertise)
+		os.Setenv(AdvertiseEnv, tt.env)
+		if got := s.advertiseAddress(tt.network, tt.address); got != tt.want {
+			t.Errorf("%d: got %v, want %v", i, got, tt.want)
+		}
+	}
+	os.Unsetenv(AdvertiseEnv)
+}
@@ -0,0 +1,299 @@
+package zserver
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/ironzhang/tlog"
+	"github.com/ironzhang/x-pearls/govern"
+	"github.com/ironzhang/zerone/pkg/endpoint"
+)
+
+// TTLDriver 支持单独设置注册存活时间的govern.Driver
+type TTLDriver interface {
+	govern.Driver
+	NewProviderWithTTL(service string, ttl, interval time.Duration, f govern.GetEndpointFunc) govern.Provider
+}
+
+// RegisterOptions 服务注册选项
+type RegisterOptions struct {
+	Interval      time.Duration         // 刷新注册信息的间隔, 默认为10s
+	TTL           time.Duration         // 注册信息的存活时间, 仅TTLDriver支持, 其它driver由driver自行决定
+	Ready         func() bool           // 就绪检查, 返回true之前不注册, 为nil时立即注册
+	ReadyInterval time.Duration         // 就绪检查的间隔, 默认为1s
+	OnEvent       func(e RegisterEvent) // 注册事件回调
+}
+
+func (o *RegisterOptions) setDefaults() {
+	if o.Interval <= 0 {
+		o.Interval = 10 * time.Second
+	}
+	if o.ReadyInterval <= 0 {
+		o.ReadyInterval = time.Second
+	}
+}
+
+// RegisterEventType 注册事件类型
+type RegisterEventType int
+
+// 注册事件类型常量定义
+const (
+	Registered      RegisterEventType = iota // 注册成功, 已可被发现
+	StillRegistered                          // 每个Interval检查一次, endpoint仍在服务的endpoint列表中, 由订阅结果推断, 不代表租约续约成功
+	Lost                                     // 注册信息意外丢失, 如租约过期, 之后会自动重新注册
+	Deregistered                             // 主动注销, 如进入维护模式或关闭服务
+)
+
+func (t RegisterEventType) String() string {
+	switch t {
+	case Registered:
+		return "REGISTERED"
+	case StillRegistered:
+		return "STILL_REGISTERED"
+	case Lost:
+		return "LOST"
+	case Deregistered:
+		return "DEREGISTERED"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// RegisterEvent 注册事件
+type RegisterEvent struct {
+	Type     RegisterEventType
+	Service  string
+	Endpoint endpoint.Endpoint
+}
+
+// registrar 管理单个endpoint的注册, 通过订阅服务的endpoint列表确认注册状态
+type registrar struct {
+	driver  govern.Driver
+	service string
+	ep      endpoint.Endpoint
+	opts    RegisterOptions
+	done    chan struct{}
+	events  chan RegisterEventType
+
+	op         sync.Mutex // 串行化注册和注销
+	mu         sync.Mutex
+	gen        int
+	provider   govern.Provider
+	consumer   govern.Consumer
+	ready      bool
+	paused     bool
+	closed     bool
+	registered bool
+}
+
+func newRegistrar(driver govern.Driver, service string, ep endpoint.Endpoint, opts RegisterOptions, paused bool) *registrar {
+	r := &registrar{
+		driver:  driver,
+		service: service,
+		ep:      ep,
+		opts:    opts,
+		done:    make(chan struct{}),
+		events:  make(chan RegisterEventType, 16),
+		paused:  paused,
+	}
+	go r.run()
+	go r.dispatching()
+	return r
+}
+
+func (r *registrar) run() {
+	if !r.waitReady() {
+		return
+	}
+	r.mu.Lock()
+	r.ready = true
+	r.mu.Unlock()
+	r.update()
+
+	t := time.NewTicker(r.opts.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			r.check()
+		case <-r.done:
+			return
+		}
+	}
+}
+
+func (r *registrar) waitReady() bool {
+	if r.opts.Ready == nil {
+		return true
+	}
+	t := time.NewTicker(r.opts.ReadyInterval)
+	defer t.Stop()
+	for !r.opts.Ready() {
+		select {
+		case <-t.C:
+		case <-r.done:
+			return false
+		}
+	}
+	return true
+}
+
+// pause 暂停或恢复注册, 暂停时注销endpoint但不关闭监听器
+func (r *registrar) pause(paused bool) {
+	r.mu.Lock()
+	r.paused = paused
+	r.mu.Unlock()
+	r.update()
+}
+
+// update 按当前状态注册或注销endpoint
+func (r *registrar) update() {
+	r.op.Lock()
+	defer r.op.Unlock()
+
+	r.mu.Lock()
+	if r.paused || r.closed || !r.ready {
+		r.mu.Unlock()
+		r.deregister()
+		return
+	}
+	if r.provider != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.gen++
+	gen := r.gen
+	r.mu.Unlock()
+
+	// 在锁外创建consumer和provider, driver可能在持有自身锁时同步回调refresh
+	consumer := r.driver.NewConsumer(r.service, &endpoint.Endpoint{}, func(eps []govern.Endpoint) {
+		r.refresh(gen, eps)
+	})
+	ep := r.ep
+	f := func() govern.Endpoint { return &ep }
+	var provider govern.Provider
+	if d, ok := r.driver.(TTLDriver); ok && r.opts.TTL > 0 {
+		provider = d.NewProviderWithTTL(r.service, r.opts.TTL, r.opts.Interval, f)
+	} else {
+		provider = r.driver.NewProvider(r.service, r.opts.Interval, f)
+	}
+
+	r.mu.Lock()
+	if r.gen != gen || r.paused || r.closed {
+		r.mu.Unlock()
+		provider.Close()
+		consumer.Close()
+		return
+	}
+	r.provider, r.consumer = provider, consumer
+	r.mu.Unlock()
+	r.refresh(gen, consumer.GetEndpoints())
+}
+
+func (r *registrar) deregister() {
+	r.mu.Lock()
+	provider, consumer, registered := r.provider, r.consumer, r.registered
+	r.gen++
+	r.provider, r.consumer, r.registered = nil, nil, false
+	r.mu.Unlock()
+
+	if provider != nil {
+		provider.Close()
+	}
+	if consumer != nil {
+		consumer.Close()
+	}
+	if registered {
+		r.emit(Deregistered)
+	}
+}
+
+// close 注销endpoint, 之后不再注册
+func (r *registrar) close() {
+	r.op.Lock()
+	r.mu.Lock()
+	r.closed = true
+	r.mu.Unlock()
+	r.deregister()
+	r.op.Unlock()
+	close(r.done)
+}
+
+// refresh 根据服务的endpoint列表更新注册状态
+func (r *registrar) refresh(gen int, eps []govern.Endpoint) {
+	present := r.present(eps)
+	r.mu.Lock()
+	if r.gen != gen || r.provider == nil || present == r.registered {
+		r.mu.Unlock()
+		return
+	}
+	r.registered = present
+	r.mu.Unlock()
+
+	if present {
+		r.emit(Registered)
+	} else {
+		r.emit(Lost)
+	}
+}
+
+// check 定期检查endpoint是否仍在服务的endpoint列表中
+func (r *registrar) check() {
+	r.mu.Lock()
+	gen, consumer, registered := r.gen, r.consumer, r.registered
+	r.mu.Unlock()
+	if consumer == nil {
+		return
+	}
+	eps := consumer.GetEndpoints()
+	if registered && r.present(eps) {
+		r.emit(StillRegistered)
+		return
+	}
+	r.refresh(gen, eps)
+}
+
+func (r *registrar) present(eps []govern.Endpoint) bool {
+	for _, ep := range eps {
+		if ep.Node() == r.ep.Node() {
+			return true
+		}
+	}
+	return false
+}
+
+// emit 异步派发事件, 避免在driver的回调中执行OnEvent, OnEvent处理过慢导致缓冲区满时丢弃事件
+func (r *registrar) emit(t RegisterEventType) {
+	if r.opts.OnEvent == nil {
+		return
+	}
+	select {
+	case r.events <- t:
+	default:
+		log.Warnf("drop register event: service=%s, endpoint=%s, event=%s", r.service, r.ep.String(), t)
+	}
+}
+
+func (r *registrar) dispatching() {
+	for {
+		select {
+		case t := <-r.events:
+			r.dispatch(t)
+		case <-r.done:
+			for {
+				select {
+				case t := <-r.events:
+					r.dispatch(t)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *registrar) dispatch(t RegisterEventType) {
+	if r.opts.OnEvent != nil {
+		r.opts.OnEvent(RegisterEvent{Type: t, Service: r.service, Endpoint: r.ep})
+	}
+}
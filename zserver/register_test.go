@@ -0,0 +1,170 @@
+package zserver
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ironzhang/x-pearls/govern"
+	"github.com/ironzhang/x-pearls/govern/stub"
+	"github.com/ironzhang/zerone/pkg/endpoint"
+)
+
+type RegisterEvents struct {
+	mu     sync.Mutex
+	events []RegisterEventType
+}
+
+func (p *RegisterEvents) OnEvent(e RegisterEvent) {
+	p.mu.Lock()
+	p.events = append(p.events, e.Type)
+	p.mu.Unlock()
+}
+
+func (p *RegisterEvents) Wait(t RegisterEventType) bool {
+	for i := 0; i < 100; i++ {
+		p.mu.Lock()
+		for i, e := range p.events {
+			if e == t {
+				p.events = p.events[i+1:]
+				p.mu.Unlock()
+				return true
+			}
+		}
+		p.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+type TTLTestDriver struct {
+	*stub.Driver
+	ttl time.Duration
+}
+
+func (d *TTLTestDriver) NewProviderWithTTL(service string, ttl, interval time.Duration, f govern.GetEndpointFunc) govern.Provider {
+	d.ttl = ttl
+	return d.NewProvider(service, interval, f)
+}
+
+func TestRegistrar(t *testing.T) {
+	d := &TTLTestDriver{Driver: stub.NewDriver("test")}
+	c := d.NewConsumer("TestRegistrar", &endpoint.Endpoint{}, nil)
+	var ready int32
+	var events RegisterEvents
+	opts := RegisterOptions{
+		Interval:      20 * time.Millisecond,
+		TTL:           time.Minute,
+		Ready:         func() bool { return atomic.LoadInt32(&ready) == 1 },
+		ReadyInterval: 10 * time.Millisecond,
+		OnEvent:       events.OnEvent,
+	}
+	r := newRegistrar(d, "TestRegistrar", endpoint.Endpoint{Name: "0", Net: "tcp", Addr: "localhost:8000"}, opts, false)
+
+	// 就绪前不注册
+	time.Sleep(50 * time.Millisecond)
+	if got := len(c.GetEndpoints()); got != 0 {
+		t.Fatalf("registered before ready: %d endpoints", got)
+	}
+	atomic.StoreInt32(&ready, 1)
+	if err := WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+	if got, want := d.ttl, time.Minute; got != want {
+		t.Errorf("ttl: got %v, want %v", got, want)
+	}
+	if !events.Wait(Registered) {
+		t.Errorf("no registered event")
+	}
+	if !events.Wait(StillRegistered) {
+		t.Errorf("no still registered event")
+	}
+
+	// 注册信息意外丢失
+	r.mu.Lock()
+	gen := r.gen
+	r.mu.Unlock()
+	r.refresh(gen, nil)
+	if !events.Wait(Lost) {
+		t.Errorf("no lost event")
+	}
+	if !events.Wait(Registered) {
+		t.Errorf("no registered event after lost")
+	}
+
+	// 维护模式
+	r.pause(true)
+	if err := WaitEndpoints(c, 0); err != nil {
+		t.Fatalf("wait endpoints in maintenance: %v", err)
+	}
+	if !events.Wait(Deregistered) {
+		t.Errorf("no deregistered event")
+	}
+	r.pause(false)
+	if err := WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints after maintenance: %v", err)
+	}
+
+	r.close()
+	if err := WaitEndpoints(c, 0); err != nil {
+		t.Fatalf("wait endpoints after close: %v", err)
+	}
+	r.pause(false)
+	time.Sleep(50 * time.Millisecond)
+	if got := len(c.GetEndpoints()); got != 0 {
+		t.Errorf("registered after close: %d endpoints", got)
+	}
+}
+
+func TestServerMaintenance(t *testing.T) {
+	d := OpenTestDriver()
+	c := d.NewConsumer("TestServerMaintenance", &endpoint.Endpoint{}, nil)
+	s := New("TestServer-0", "TestServerMaintenance", d)
+	s.SetRegisterOptions(RegisterOptions{Interval: 20 * time.Millisecond})
+	s.SetMaintenance(true)
+	go s.ListenAndServe("tcp", "localhost:0", "")
+
+	time.Sleep(50 * time.Millisecond)
+	if got := len(c.GetEndpoints()); got != 0 {
+		t.Fatalf("registered in maintenance: %d endpoints", got)
+	}
+	s.SetMaintenance(false)
+	if err := WaitEndpoints(c, 1); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+	s.SetMaintenance(true)
+	if err := WaitEndpoints(c, 0); err != nil {
+		t.Fatalf("wait endpoints in maintenance: %v", err)
+	}
+	if got := len(s.Addrs()); got != 1 {
+		t.Errorf("listener is closed in maintenance")
+	}
+	s.Close()
+}
+
+func TestRegistrarEmitNonBlocking(t *testing.T) {
+	block := make(chan struct{})
+	defer close(block)
+	r := &registrar{
+		opts:   RegisterOptions{OnEvent: func(RegisterEvent) { <-block }},
+		done:   make(chan struct{}),
+		events: make(chan RegisterEventType, 16),
+	}
+	go r.dispatching()
+	defer close(r.done)
+
+	// OnEvent阻塞时, 缓冲区满后丢弃事件而不阻塞调用者
+	finished := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			r.emit(StillRegistered)
+		}
+		close(finished)
+	}()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Fatalf("emit blocked")
+	}
+}
@@ -1,31 +1,44 @@
 package zserver
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/ironzhang/x-pearls/govern"
 	"github.com/ironzhang/zerone/pkg/endpoint"
 	"github.com/ironzhang/zerone/rpc"
+	"github.com/ironzhang/zerone/rpc/memconn"
 	"github.com/ironzhang/zerone/rpc/trace"
 )
 
+// ErrServerClosed 服务器已关闭
+var ErrServerClosed = errors.New("zserver: server closed")
+
 type Server struct {
 	server  *rpc.Server
 	service string
 	driver  govern.Driver
 
-	mu  sync.Mutex
-	lns []net.Listener
+	mu          sync.Mutex
+	lns         []net.Listener
+	closed      bool
+	advertise   string
+	ropts       RegisterOptions
+	maintenance bool
+	registrars  map[*registrar]struct{}
 }
 
 func New(name, service string, driver govern.Driver) *Server {
 	return &Server{
-		server:  rpc.NewServer(name),
-		service: service,
-		driver:  driver,
+		server:     rpc.NewServer(name),
+		service:    service,
+		driver:     driver,
+		registrars: make(map[*registrar]struct{}),
 	}
 }
 
@@ -35,10 +48,22 @@ func (s *Server) Close() error {
 		ln.Close()
 	}
 	s.lns = nil
+	s.closed = true
 	s.mu.Unlock()
 	return nil
 }
 
+// Addrs 返回所有正在监听的地址
+func (s *Server) Addrs() []net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	addrs := make([]net.Addr, 0, len(s.lns))
+	for _, ln := range s.lns {
+		addrs = append(addrs, ln.Addr())
+	}
+	return addrs
+}
+
 func (s *Server) SetTraceOutput(out trace.Output) {
 	s.server.SetTraceOutput(out)
 }
@@ -51,6 +76,43 @@ func (s *Server) SetTraceVerbose(verbose int) {
 	s.server.SetTraceVerbose(verbose)
 }
 
+// SetRegisterOptions 设置服务注册选项, 只对之后开始监听的endpoint生效
+func (s *Server) SetRegisterOptions(opts RegisterOptions) {
+	s.mu.Lock()
+	s.ropts = opts
+	s.mu.Unlock()
+}
+
+// SetMaintenance 设置维护模式, 维护模式下注销所有endpoint但不关闭监听器, 已建立的连接不受影响
+func (s *Server) SetMaintenance(on bool) {
+	s.mu.Lock()
+	s.maintenance = on
+	registrars := make([]*registrar, 0, len(s.registrars))
+	for r := range s.registrars {
+		registrars = append(registrars, r)
+	}
+	s.mu.Unlock()
+
+	for _, r := range registrars {
+		r.pause(on)
+	}
+}
+
+// SetIdleTimeout 设置连接的空闲超时时间, 连接超过该时间没有收到任何数据(包括心跳)时关闭, 为0时不关闭
+func (s *Server) SetIdleTimeout(d time.Duration) {
+	s.server.SetIdleTimeout(d)
+}
+
+// SetAuthenticator 设置请求认证器
+func (s *Server) SetAuthenticator(a rpc.Authenticator) {
+	s.server.SetAuthenticator(a)
+}
+
+// SetACL 设置方法的访问控制列表
+func (s *Server) SetACL(acl rpc.ACL) {
+	s.server.SetACL(acl)
+}
+
 func (s *Server) Register(rcvr interface{}) error {
 	return s.server.Register(rcvr)
 }
@@ -59,32 +121,119 @@ func (s *Server) RegisterName(name string, rcvr interface{}) error {
 	return s.server.RegisterName(name, rcvr)
 }
 
+// ListenAndServe 监听并处理连接, network除net.Listen支持的网络类型外, 还支持进程内的memconn.Network.
+// address的端口为0时, 注册的endpoint使用实际监听的端口
 func (s *Server) ListenAndServe(network, address, endpointName string) (err error) {
-	ln, err := net.Listen(network, address)
+	ln, err := listen(network, address)
 	if err != nil {
 		return err
 	}
-	s.addListener(ln)
+	return s.serve(ln, network, boundAddress(address, ln.Addr()), endpointName, false)
+}
+
+// ListenAndServeTLS 监听TLS连接, 注册的endpoint声明了TLS, 客户端会自动使用TLS连接.
+// 需要校验客户端证书(mTLS)时, 设置config的ClientAuth和ClientCAs
+func (s *Server) ListenAndServeTLS(network, address, endpointName string, config *tls.Config) (err error) {
+	ln, err := listen(network, address)
+	if err != nil {
+		return err
+	}
+	return s.serve(tls.NewListener(ln, config), network, boundAddress(address, ln.Addr()), endpointName, true)
+}
+
+// Serve 在已创建的监听器上处理连接, 用于socket激活等场景, 注册的endpoint使用监听器的地址.
+// 可以在多个监听器上同时调用Serve, 每个监听器注册各自的endpoint
+func (s *Server) Serve(ln net.Listener, endpointName string) error {
+	return s.serve(ln, ln.Addr().Network(), ln.Addr().String(), endpointName, false)
+}
+
+// ServeTLS 在已创建的监听器上处理TLS连接
+func (s *Server) ServeTLS(ln net.Listener, endpointName string, config *tls.Config) error {
+	return s.serve(tls.NewListener(ln, config), ln.Addr().Network(), ln.Addr().String(), endpointName, true)
+}
+
+func (s *Server) serve(ln net.Listener, network, address, endpointName string, secure bool) error {
+	if err := s.addListener(ln); err != nil {
+		ln.Close()
+		return err
+	}
 
 	if s.driver != nil {
+		address = s.advertiseAddress(network, address)
 		if endpointName == "" {
 			endpointName = fmt.Sprintf("%s@%s", network, address)
 		}
-		p := s.driver.NewProvider(s.service, 10*time.Second, func() govern.Endpoint {
-			return &endpoint.Endpoint{
-				Name: endpointName,
-				Net:  network,
-				Addr: address,
-			}
+		r := s.register(endpoint.Endpoint{
+			Name: endpointName,
+			Net:  network,
+			Addr: address,
+			TLS:  secure,
 		})
-		defer p.Close()
+		defer s.deregister(r)
 	}
 	s.server.Accept(ln)
 	return nil
 }
 
-func (s *Server) addListener(ln net.Listener) {
+func listen(network, address string) (net.Listener, error) {
+	switch network {
+	case memconn.Network:
+		return memconn.Listen(address)
+	case "unix":
+		removeStaleSocket(address)
+	}
+	return net.Listen(network, address)
+}
+
+// removeStaleSocket 删除上次进程异常退出后残留的unix socket文件, 仍有进程在监听时不删除
+func removeStaleSocket(address string) {
+	fi, err := os.Stat(address)
+	if err != nil || fi.Mode()&os.ModeSocket == 0 {
+		return
+	}
+	if conn, err := net.Dial("unix", address); err == nil {
+		conn.Close()
+		return
+	}
+	os.Remove(address)
+}
+
+// boundAddress 监听地址的端口为0时, 返回以实际监听端口替换后的地址
+func boundAddress(address string, addr net.Addr) string {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil || port != "0" {
+		return address
+	}
+	_, bound, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return address
+	}
+	return net.JoinHostPort(host, bound)
+}
+
+func (s *Server) register(ep endpoint.Endpoint) *registrar {
 	s.mu.Lock()
-	s.lns = append(s.lns, ln)
+	defer s.mu.Unlock()
+	opts := s.ropts
+	opts.setDefaults()
+	r := newRegistrar(s.driver, s.service, ep, opts, s.maintenance)
+	s.registrars[r] = struct{}{}
+	return r
+}
+
+func (s *Server) deregister(r *registrar) {
+	s.mu.Lock()
+	delete(s.registrars, r)
 	s.mu.Unlock()
+	r.close()
+}
+
+func (s *Server) addListener(ln net.Listener) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrServerClosed
+	}
+	s.lns = append(s.lns, ln)
+	return nil
 }
@@ -2,15 +2,30 @@ package zserver
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"errors"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/ironzhang/x-pearls/govern"
 	"github.com/ironzhang/x-pearls/govern/stub"
 	"github.com/ironzhang/zerone/pkg/endpoint"
+	"github.com/ironzhang/zerone/pkg/route/dtable"
+	"github.com/ironzhang/zerone/pkg/route/stable"
 	"github.com/ironzhang/zerone/rpc"
+	"github.com/ironzhang/zerone/rpc/memconn"
+	"github.com/ironzhang/zerone/zclient"
 )
 
 type Echo struct{}
@@ -56,7 +71,7 @@ func TestServerWithDriver(t *testing.T) {
 	for _, tt := range tests {
 		go func(net, addr string) {
 			if err := s.ListenAndServe(net, addr, ""); err != nil {
-				t.Fatalf("listen and serve: %v", err)
+				t.Errorf("listen and serve: %v", err)
 			}
 		}(tt.net, tt.addr)
 	}
@@ -109,7 +124,7 @@ func TestServerWithoutDriver(t *testing.T) {
 	for _, tt := range tests {
 		go func(net, addr string) {
 			if err := s.ListenAndServe(net, addr, ""); err != nil {
-				t.Fatalf("listen and serve: %v", err)
+				t.Errorf("listen and serve: %v", err)
 			}
 		}(tt.net, tt.addr)
 	}
@@ -136,3 +151,225 @@ func TestServerWithoutDriver(t *testing.T) {
 
 	s.Close()
 }
+
+// NewSelfSignedCert 生成localhost的自签名证书
+func NewSelfSignedCert(t *testing.T) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:              []string{"localhost"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("parse certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}
+}
+
+func TestServerTLS(t *testing.T) {
+	cert := NewSelfSignedCert(t)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert.Leaf)
+
+	d := OpenTestDriver()
+	s := New("TestServerTLS-0", "TestServerTLS", d)
+	if err := s.Register(Echo{}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	defer s.Close()
+	go func() {
+		if err := s.ListenAndServeTLS("tcp", "localhost:5010", "", &tls.Config{Certificates: []tls.Certificate{cert}}); err != nil {
+			t.Errorf("listen and serve tls: %v", err)
+		}
+	}()
+
+	tb := dtable.NewTable(d, "TestServerTLS")
+	defer tb.Close()
+	for i := 0; i < 100 && len(tb.ListEndpoints()) <= 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	eps := tb.ListEndpoints()
+	if len(eps) != 1 || !eps[0].TLS {
+		t.Fatalf("endpoints: %v", eps)
+	}
+
+	// 客户端根据endpoint的TLS声明自动使用TLS连接
+	opts := zclient.Options{Connection: zclient.ConnectionOptions{Dial: rpc.DialOptions{TLSConfig: &tls.Config{RootCAs: pool}}}}
+	c := zclient.NewWithOptions("TestClient", tb, opts)
+	defer c.Close()
+
+	req, reply := "hello", ""
+	if err := c.Call(context.Background(), nil, "Echo.Echo", req, &reply, time.Second); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	if got, want := reply, req; got != want {
+		t.Fatalf("reply: got %v, want %v", got, want)
+	}
+}
+
+func TestServerTransports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zserver")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		net  string
+		addr string
+	}{
+		{net: "unix", addr: filepath.Join(dir, "echo.sock")},
+		{net: memconn.Network, addr: "TestServerTransports"},
+	}
+	for _, tt := range tests {
+		s := New("TestServer-0", "TestServer", nil)
+		if err := s.Register(Echo{}); err != nil {
+			t.Fatalf("register: %v", err)
+		}
+		go func(net, addr string) {
+			if err := s.ListenAndServe(net, addr, ""); err != nil {
+				t.Errorf("listen and serve: %v", err)
+			}
+		}(tt.net, tt.addr)
+		time.Sleep(50 * time.Millisecond)
+
+		tb := stable.NewTable([]endpoint.Endpoint{{Name: "0", Net: tt.net, Addr: tt.addr}})
+		c := zclient.New("TestClient", tb)
+		req, reply := "hello", ""
+		if err := c.Call(context.Background(), nil, "Echo.Echo", req, &reply, time.Second); err != nil {
+			t.Errorf("%s: call: %v", tt.net, err)
+		} else if got, want := reply, req; got != want {
+			t.Errorf("%s: reply: got %v, want %v", tt.net, got, want)
+		}
+		c.Close()
+		s.Close()
+	}
+}
+
+func TestRemoveStaleSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zserver")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	// 模拟异常退出后残留的socket文件
+	addr := filepath.Join(dir, "stale.sock")
+	ln, err := net.Listen("unix", addr)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	ln.Close()
+
+	if ln, err = listen("unix", addr); err != nil {
+		t.Fatalf("listen on stale socket: %v", err)
+	}
+	defer ln.Close()
+	if _, err = listen("unix", addr); err == nil {
+		t.Errorf("listen on active socket should fail")
+	}
+}
+
+func TestServerServe(t *testing.T) {
+	dir, err := ioutil.TempDir("", "zserver")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := OpenTestDriver()
+	c := d.NewConsumer("TestServe", &endpoint.Endpoint{}, nil)
+	s := New("TestServe-0", "TestServe", d)
+	if err := s.Register(Echo{}); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+
+	tcp, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	unix, err := net.Listen("unix", filepath.Join(dir, "echo.sock"))
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	done := make(chan error, 2)
+	for _, ln := range []net.Listener{tcp, unix} {
+		go func(ln net.Listener) {
+			done <- s.Serve(ln, "")
+		}(ln)
+	}
+
+	if err = WaitEndpoints(c, 2); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+	if got, want := len(s.Addrs()), 2; got != want {
+		t.Errorf("addrs: got %v, want %v", got, want)
+	}
+	addrs := map[string]bool{tcp.Addr().String(): true, unix.Addr().String(): true}
+	for i, p := range c.GetEndpoints() {
+		ep := p.(*endpoint.Endpoint)
+		if !addrs[ep.Addr] {
+			t.Errorf("%d: unexpected endpoint addr %q", i, ep.Addr)
+			continue
+		}
+		rc, err := rpc.Dial("TestClient", ep.Net, ep.Addr)
+		if err != nil {
+			t.Fatalf("%d: dial: %v", i, err)
+		}
+		req, reply := "hello", ""
+		if err = rc.Call(context.Background(), "Echo.Echo", req, &reply, 0); err != nil {
+			t.Errorf("%d: call: %v", i, err)
+		} else if got, want := reply, req; got != want {
+			t.Errorf("%d: reply: got %v, want %v", i, got, want)
+		}
+		rc.Close()
+	}
+
+	s.Close()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("serve is not returned after close")
+		}
+	}
+	if err = WaitEndpoints(c, 0); err != nil {
+		t.Fatalf("wait endpoints: %v", err)
+	}
+	if err = s.ListenAndServe("tcp", "localhost:0", ""); err != ErrServerClosed {
+		t.Errorf("listen and serve after close: got %v, want %v", err, ErrServerClosed)
+	}
+}
+
+func TestBoundAddress(t *testing.T) {
+	bound := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 5678}
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{address: "localhost:0", want: "localhost:5678"},
+		{address: ":0", want: ":5678"},
+		{address: "localhost:5000", want: "localhost:5000"},
+		{address: "/tmp/echo.sock", want: "/tmp/echo.sock"},
+	}
+	for i, tt := range tests {
+		if got := boundAddress(tt.address, bound); got != tt.want {
+			t.Errorf("%d: got %v, want %v", i, got, tt.want)
+		}
+	}
+}